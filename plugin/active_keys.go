@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// limitActiveKeys checks that issuing the given number of keys from a role stays
+// within the max_active_keys of the role and of the config, and reserves them
+// so that concurrent requests can't exceed the limit while the keys are
+// created. The returned function releases the reservation and must be called
+// once the issued keys are recorded, or their creation failed.
+func (b *datadogBackend) limitActiveKeys(ctx context.Context, s logical.Storage, roleEntry *datadogRoleEntry, keys int) (func(), *logical.Response, error) {
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting config: %w", err)
+	}
+
+	maxActiveKeys := 0
+	if config != nil {
+		maxActiveKeys = config.MaxActiveKeys
+	}
+
+	if maxActiveKeys <= 0 && roleEntry.MaxActiveKeys <= 0 {
+		return func() {}, nil, nil
+	}
+
+	b.activeKeysLock.Lock()
+	defer b.activeKeysLock.Unlock()
+
+	if err := b.loadActiveKeys(ctx, s); err != nil {
+		return nil, nil, fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	// keys reserved by other requests count as active
+	active := len(b.activeKeys)
+	roleActive := b.roleActiveKeys[roleEntry.Name] + b.reservedKeys[roleEntry.Name]
+	for _, reserved := range b.reservedKeys {
+		active += reserved
+	}
+
+	if roleEntry.MaxActiveKeys > 0 && roleActive+keys > roleEntry.MaxActiveKeys {
+		return nil, logical.ErrorResponse("role %q has %d active keys and may not have more than %d", roleEntry.Name, roleActive, roleEntry.MaxActiveKeys), nil
+	}
+
+	if maxActiveKeys > 0 && active+keys > maxActiveKeys {
+		return nil, logical.ErrorResponse("%d keys are active and no more than %d may be", active, maxActiveKeys), nil
+	}
+
+	b.reservedKeys[roleEntry.Name] += keys
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			b.activeKeysLock.Lock()
+			defer b.activeKeysLock.Unlock()
+
+			b.reservedKeys[roleEntry.Name] -= keys
+			if b.reservedKeys[roleEntry.Name] <= 0 {
+				delete(b.reservedKeys, roleEntry.Name)
+			}
+		})
+	}
+
+	return release, nil, nil
+}
+
+// loadActiveKeys reads the active issued keys from storage, unless
+// they are already known. It must be called with activeKeysLock held.
+func (b *datadogBackend) loadActiveKeys(ctx context.Context, s logical.Storage) error {
+
+	if b.activeKeys != nil {
+		return nil
+	}
+
+	issued, err := listIssuedKeys(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	b.activeKeys = make(map[string]string)
+	b.roleActiveKeys = make(map[string]int)
+	for _, k := range issued {
+		if k.active() {
+			b.activeKeys[k.KeyID] = k.Role
+			b.roleActiveKeys[k.Role]++
+		}
+	}
+
+	return nil
+}
+
+// trackActiveKey records whether an issued key is active after its
+// record was written or deleted. Keys are only tracked once they
+// have been loaded, as loading reads every record written before.
+func (b *datadogBackend) trackActiveKey(keyID string, role string, active bool) {
+	b.activeKeysLock.Lock()
+	defer b.activeKeysLock.Unlock()
+
+	if b.activeKeys == nil {
+		return
+	}
+
+	if previous, ok := b.activeKeys[keyID]; ok {
+		delete(b.activeKeys, keyID)
+		b.roleActiveKeys[previous]--
+		if b.roleActiveKeys[previous] <= 0 {
+			delete(b.roleActiveKeys, previous)
+		}
+	}
+
+	if active {
+		b.activeKeys[keyID] = role
+		b.roleActiveKeys[role]++
+	}
+}
+
+// forgetActiveKeys drops the active keys, so that they are
+// loaded again from storage
+func (b *datadogBackend) forgetActiveKeys() {
+	b.activeKeysLock.Lock()
+	defer b.activeKeysLock.Unlock()
+
+	b.activeKeys = nil
+	b.roleActiveKeys = nil
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxActiveKeys(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	issue := func(path string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Storage:   s,
+		})
+	}
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"max_active_keys": 2,
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, "other", map[string]interface{}{})
+	require.NoError(t, err)
+
+	t.Run("Role", func(t *testing.T) {
+		first, err := issue(apiKeyPath + roleName)
+		require.NoError(t, err)
+		require.False(t, first.IsError())
+
+		// creds would take the role over its limit
+		resp, err := issue(credsPath + roleName)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		resp, err = issue(appKeyPath + roleName)
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		resp, err = issue(apiKeyPath + roleName)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		// other roles are not affected
+		resp, err = issue(apiKeyPath + "other")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		// revoking a key frees its slot
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    first.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		resp, err = issue(apiKeyPath + roleName)
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"max_active_keys": 4,
+		})
+		require.NoError(t, err)
+
+		resp, err := issue(apiKeyPath + "other")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		resp, err = issue(apiKeyPath + "other")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		apiKeys, appKeys := srv.keyCount()
+		require.Equal(t, 4, apiKeys+appKeys)
+	})
+
+	t.Run("Reserved", func(t *testing.T) {
+		roleEntry := &datadogRoleEntry{Name: "reserved", MaxActiveKeys: 2}
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"max_active_keys": 0,
+		})
+		require.NoError(t, err)
+
+		release, resp, err := b.limitActiveKeys(context.Background(), s, roleEntry, 2)
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		// the keys being created count against the limit
+		// without blocking other requests
+		_, resp, err = b.limitActiveKeys(context.Background(), s, roleEntry, 1)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		release()
+		release()
+		require.Empty(t, b.reservedKeys)
+
+		release, resp, err = b.limitActiveKeys(context.Background(), s, roleEntry, 2)
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		release()
+	})
+
+	t.Run("Counted Without Listing", func(t *testing.T) {
+		b.activeKeysLock.Lock()
+		require.Equal(t, 2, b.roleActiveKeys[roleName])
+		require.Len(t, b.activeKeys, 4)
+		b.activeKeysLock.Unlock()
+
+		// the active keys are only read from storage once
+		unlisted := &failingListStorage{Storage: s}
+		roleEntry := &datadogRoleEntry{Name: roleName, MaxActiveKeys: 2}
+		_, resp, err := b.limitActiveKeys(context.Background(), unlisted, roleEntry, 1)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		b.invalidate(context.Background(), issuedKeyStoragePrefix+"replicated")
+		_, _, err = b.limitActiveKeys(context.Background(), unlisted, roleEntry, 1)
+		require.Error(t, err)
+
+		_, resp, err = b.limitActiveKeys(context.Background(), s, roleEntry, 1)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+// failingListStorage fails every listing
+type failingListStorage struct {
+	logical.Storage
+}
+
+func (s *failingListStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return nil, errors.New("storage unavailable")
+}
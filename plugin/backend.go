@@ -2,10 +2,12 @@ package plugin
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -15,8 +17,26 @@ var Version = "v0.1.2"
 // and stores the datadog API Client
 type datadogBackend struct {
 	*framework.Backend
-	lock   sync.RWMutex
-	client *datadogClient
+	lock    sync.RWMutex
+	client  *datadogClient
+	limiter *keyCreationLimiter
+
+	// issueLocks serialize the issuance of a user or an
+	// elevation from its outstanding check to its record
+	issueLocks []*locksutil.LockEntry
+
+	// rootRotationLock serializes rotations of the
+	// root keys, including their rollback
+	rootRotationLock sync.Mutex
+
+	// activeKeysLock guards the roles of the active issued
+	// keys by key ID, their count by role, and the keys
+	// reserved by role by issuances yet to record them while
+	// a max_active_keys limit applies
+	activeKeysLock sync.Mutex
+	activeKeys     map[string]string
+	roleActiveKeys map[string]int
+	reservedKeys   map[string]int
 }
 
 // backendHelp defines the helptext for the datadog backend
@@ -40,7 +60,11 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 // secrets it will store
 func newBackend() *datadogBackend {
 
-	var b = datadogBackend{}
+	var b = datadogBackend{
+		limiter:      newKeyCreationLimiter(),
+		reservedKeys: make(map[string]int),
+		issueLocks:   locksutil.CreateLocks(),
+	}
 	b.Backend = &framework.Backend{
 		Help: strings.TrimSpace(backendHelp),
 		PathsSpecial: &logical.Paths{
@@ -48,24 +72,44 @@ func newBackend() *datadogBackend {
 			SealWrapStorage: []string{
 				"config",
 				"role/*",
+				"static-roles/*",
 			},
 		},
 		Paths: framework.PathAppend(
 			pathRole(&b),
+			pathStaticRole(&b),
+			pathKeys(&b),
+			pathTidy(&b),
 			[]*framework.Path{
 				pathConfig(&b),
 				pathConfigRotate(&b),
+				pathConfigPendingDeletions(&b),
+				pathRoleRevokeAll(&b),
+				pathRateLimits(&b),
 				pathAPIKey(&b),
 				pathAppKey(&b),
+				pathCreds(&b),
+				pathServiceAccount(&b),
+				pathUser(&b),
+				pathElevation(&b),
+				pathStaticCreds(&b),
 			},
 		),
 		Secrets: []*framework.Secret{
 			b.datadogAPIKey(),
 			b.datadogAppKey(),
+			b.datadogCreds(),
+			b.datadogServiceAccount(),
+			b.datadogUser(),
+			b.datadogElevation(),
 		},
-		BackendType:    logical.TypeLogical,
-		Invalidate:     b.invalidate,
-		RunningVersion: Version,
+		BackendType:       logical.TypeLogical,
+		Invalidate:        b.invalidate,
+		PeriodicFunc:      b.periodicFunc,
+		RotateCredential:  b.rotateCredential,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: walRollbackMinAge,
+		RunningVersion:    Version,
 	}
 
 	return &b
@@ -80,9 +124,37 @@ func (b *datadogBackend) reset() {
 
 // invalidate clears an existing datadog client configuration within the backend
 func (b *datadogBackend) invalidate(ctx context.Context, key string) {
-	if key == "config" {
+	switch {
+	case key == "config":
 		b.reset()
+	case strings.HasPrefix(key, issuedKeyStoragePrefix):
+		b.forgetActiveKeys()
+	}
+}
+
+// periodicFunc is invoked by Vault on every rollback tick to
+// perform background maintenance
+func (b *datadogBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+
+	// deleting keys writes to storage, which isn't possible
+	// on performance standbys and secondaries
+	if !b.WriteSafeReplicationState() {
+		return nil
+	}
+
+	if err := b.processPendingDeletions(ctx, req.Storage); err != nil {
+		return fmt.Errorf("error processing pending deletions: %w", err)
+	}
+
+	if err := b.rotateDueStaticRoles(ctx, req.Storage); err != nil {
+		return fmt.Errorf("error rotating static roles: %w", err)
 	}
+
+	if err := b.autoTidy(ctx, req.Storage); err != nil {
+		return fmt.Errorf("error tidying orphaned keys: %w", err)
+	}
+
+	return nil
 }
 
 // getClient locks the datadog backend as it configures and creates a new
@@ -6,6 +6,7 @@ import (
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/rotation"
 )
 
 func getTestBackend(tb testing.TB) (*datadogBackend, logical.Storage) {
@@ -14,7 +15,10 @@ func getTestBackend(tb testing.TB) (*datadogBackend, logical.Storage) {
 	config := logical.TestBackendConfig()
 	config.StorageView = new(logical.InmemStorage)
 	config.Logger = hclog.NewNullLogger()
-	config.System = logical.TestSystemView()
+	config.System = &testSystemView{
+		StaticSystemView: logical.TestSystemView(),
+		rotationJobs:     map[string]*rotation.RotationJobConfigureRequest{},
+	}
 
 	b, err := Factory(context.Background(), config)
 	if err != nil {
@@ -23,3 +27,20 @@ func getTestBackend(tb testing.TB) (*datadogBackend, logical.Storage) {
 
 	return b.(*datadogBackend), config.StorageView
 }
+
+// testSystemView records rotation jobs that the StaticSystemView
+// does not implement
+type testSystemView struct {
+	*logical.StaticSystemView
+	rotationJobs map[string]*rotation.RotationJobConfigureRequest
+}
+
+func (v *testSystemView) RegisterRotationJob(_ context.Context, req *rotation.RotationJobConfigureRequest) (string, error) {
+	v.rotationJobs[req.ReqPath] = req
+	return req.ReqPath, nil
+}
+
+func (v *testSystemView) DeregisterRotationJob(_ context.Context, req *rotation.RotationJobDeregisterRequest) error {
+	delete(v.rotationJobs, req.ReqPath)
+	return nil
+}
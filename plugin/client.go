@@ -4,13 +4,116 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
 )
 
+const (
+	// indexes into the server configurations of datadog.NewConfiguration
+	siteServerIndex = 0
+	urlServerIndex  = 1
+
+	defaultMaxRetries     = 3
+	defaultRequestTimeout = 30 * time.Second
+
+	// bounds of the delay between two attempts of a request
+	// when datadog doesn't say how long to wait
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// datadogAPIError is returned when the datadog API responds
+// with an unsuccessful HTTP status
+type datadogAPIError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *datadogAPIError) Error() string {
+	return fmt.Sprintf("%s (status %d)", e.Err, e.StatusCode)
+}
+
+func (e *datadogAPIError) Unwrap() error {
+	return e.Err
+}
+
+// wrapAPIError attaches the HTTP status of a failed datadog
+// API call to its error
+func wrapAPIError(resp *http.Response, err error) error {
+	if err == nil || resp == nil {
+		return err
+	}
+	return &datadogAPIError{StatusCode: resp.StatusCode, Err: err}
+}
+
+// statusCode returns the HTTP status carried by err, or 0 if err
+// did not come from a datadog API response
+func statusCode(err error) int {
+	var apiErr *datadogAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+const (
+	// number of keys requested per page when listing keys
+	listKeysPageSize = 100
+)
+
+// classifyAPIError describes why a datadog API call failed and
+// reports whether retrying it may succeed. Errors without an HTTP
+// status, such as network failures, are considered transient.
+func classifyAPIError(err error) (string, bool) {
+	switch code := statusCode(err); {
+	case code == http.StatusUnauthorized:
+		return "datadog rejected the configured API or application key", false
+	case code == http.StatusForbidden:
+		return "the configured application key lacks the required permissions", false
+	case code == http.StatusNotFound:
+		return "the datadog resource was not found", false
+	case code == http.StatusTooManyRequests:
+		return "the datadog API rate limit was exceeded", true
+	case code >= http.StatusInternalServerError:
+		return "the datadog API is unavailable", true
+	case code == 0:
+		return "the datadog API could not be reached", true
+	default:
+		return "datadog rejected the request", false
+	}
+}
+
+// datadogKeyDetails describes an existing datadog API or
+// application key without its secret value
+type datadogKeyDetails struct {
+	ID        string
+	Name      string
+	Last4     string
+	Scopes    []string
+	CreatedAt time.Time
+}
+
+// datadogUser is a datadog user or service account
+type datadogUser struct {
+	ID       string
+	Email    string
+	Disabled bool
+	RoleIDs  []string
+}
+
 type datadogClient struct {
 	*datadog.APIClient
+	serverIndex     int
+	serverVariables map[string]string
+	maxRetries      int
+	requestTimeout  time.Duration
 }
 
 func NewClient(config *datadogConfig) (*datadogClient, error) {
@@ -31,7 +134,100 @@ func NewClient(config *datadogConfig) (*datadogClient, error) {
 	conf.AddDefaultHeader("DD-APPLICATION-KEY", config.AppKey)
 	c := datadog.NewAPIClient(conf)
 
-	return &datadogClient{c}, nil
+	client := &datadogClient{
+		APIClient:   c,
+		serverIndex: siteServerIndex,
+		serverVariables: map[string]string{
+			"site": config.getSite(),
+		},
+		maxRetries:     config.getMaxRetries(),
+		requestTimeout: config.getRequestTimeout(),
+	}
+
+	// an explicit API URL takes precedence over the site
+	if config.APIURL != "" {
+		protocol, name, err := parseAPIURL(config.APIURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid datadog API URL: %w", err)
+		}
+		client.serverIndex = urlServerIndex
+		client.serverVariables = map[string]string{
+			"protocol": protocol,
+			"name":     name,
+		}
+	}
+
+	return client, nil
+}
+
+// withServerVariables returns a context carrying the server index and
+// variables used by the datadog API client to build request URLs, so that
+// requests are sent to the configured datadog site or API URL
+func (c *datadogClient) withServerVariables(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, datadog.ContextServerIndex, c.serverIndex)
+	return context.WithValue(ctx, datadog.ContextServerVariables, c.serverVariables)
+}
+
+// retry makes a datadog API call, retrying it with exponential backoff
+// while datadog is rate limiting requests or failing. Calls that are
+// not idempotent are only retried when rate limited, as a failed
+// attempt may still have taken effect. Every attempt is bounded by
+// the request timeout.
+func (c *datadogClient) retry(ctx context.Context, idempotent bool, call func(ctx context.Context) (*http.Response, error)) error {
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+		httpResp, err := call(attemptCtx)
+		cancel()
+
+		err = wrapAPIError(httpResp, err)
+		if err == nil {
+			return nil
+		}
+
+		code := statusCode(err)
+		retryable := code == http.StatusTooManyRequests ||
+			(idempotent && (code >= http.StatusInternalServerError || code == 0))
+		if !retryable || attempt >= c.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(retryDelay(httpResp, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay returns how long to wait before the next attempt of a
+// request, preferring the delay asked for by datadog's Retry-After
+// or X-RateLimit-Reset headers
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return min(time.Duration(seconds)*time.Second, retryMaxDelay)
+			}
+			if t, err := http.ParseTime(retryAfter); err == nil {
+				return min(max(time.Until(t), 0), retryMaxDelay)
+			}
+		}
+
+		// datadog sends the number of seconds until the
+		// rate limit period resets
+		if seconds, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Reset")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, retryMaxDelay)
+		}
+	}
+
+	delay := min(retryBaseDelay<<attempt, retryMaxDelay)
+
+	// jitter spreads out the retries of concurrent requests
+	return delay/2 + rand.N(delay/2+1)
 }
 
 func (c *datadogClient) createAPIKey(ctx context.Context, apiKeyName string) (*datadogAPIKey, error) {
@@ -44,8 +240,13 @@ func (c *datadogClient) createAPIKey(ctx context.Context, apiKeyName string) (*d
 	}
 
 	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
 
-	ddresp, _, err := api.CreateAPIKey(ctx, body)
+	var ddresp datadogV2.APIKeyResponse
+	err := c.retry(ctx, false, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.CreateAPIKey(ctx, body)
+		return httpResp, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating datadog API key; %w", err)
 	}
@@ -60,8 +261,11 @@ func (c *datadogClient) createAPIKey(ctx context.Context, apiKeyName string) (*d
 func (c *datadogClient) deleteAPIKey(ctx context.Context, apiKeyID string) error {
 
 	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
 
-	_, err := api.DeleteAPIKey(ctx, apiKeyID)
+	err := c.retry(ctx, true, func(ctx context.Context) (*http.Response, error) {
+		return api.DeleteAPIKey(ctx, apiKeyID)
+	})
 	if err != nil {
 		return fmt.Errorf("error deleting datadog API key: %w", err)
 	}
@@ -85,8 +289,13 @@ func (c *datadogClient) createAppKey(ctx context.Context, name string, scopes []
 	}
 
 	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
 
-	ddresp, _, err := api.CreateCurrentUserApplicationKey(ctx, body)
+	var ddresp datadogV2.ApplicationKeyResponse
+	err := c.retry(ctx, false, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.CreateCurrentUserApplicationKey(ctx, body)
+		return httpResp, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating datadog application key: %w", err)
 	}
@@ -102,11 +311,521 @@ func (c *datadogClient) createAppKey(ctx context.Context, name string, scopes []
 func (c *datadogClient) deleteAppKey(ctx context.Context, appKeyID string) error {
 
 	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
 
-	_, err := api.DeleteApplicationKey(ctx, appKeyID)
+	err := c.retry(ctx, true, func(ctx context.Context) (*http.Response, error) {
+		return api.DeleteApplicationKey(ctx, appKeyID)
+	})
 	if err != nil {
 		return fmt.Errorf("error deleting datadog application key: %w", err)
 	}
 
 	return nil
 }
+
+// createServiceAccountAppKey creates an application key owned
+// by a datadog service account
+func (c *datadogClient) createServiceAccountAppKey(ctx context.Context, serviceAccountID string, name string, scopes []string) (*datadogAppKey, error) {
+
+	ns := datadog.NewNullableList[string](&scopes)
+
+	body := datadogV2.ApplicationKeyCreateRequest{
+		Data: datadogV2.ApplicationKeyCreateData{
+			Attributes: datadogV2.ApplicationKeyCreateAttributes{
+				Name:   name,
+				Scopes: *ns,
+			},
+			Type: datadogV2.APPLICATIONKEYSTYPE_APPLICATION_KEYS,
+		},
+	}
+
+	api := datadogV2.NewServiceAccountsApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV2.ApplicationKeyResponse
+	err := c.retry(ctx, false, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.CreateServiceAccountApplicationKey(ctx, serviceAccountID, body)
+		return httpResp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating datadog service account application key: %w", err)
+	}
+
+	respData := ddresp.GetData()
+
+	return &datadogAppKey{
+		AppKeyID: respData.GetId(),
+		AppKey:   respData.Attributes.GetKey(),
+	}, nil
+}
+
+func (c *datadogClient) deleteServiceAccountAppKey(ctx context.Context, serviceAccountID string, appKeyID string) error {
+
+	api := datadogV2.NewServiceAccountsApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	err := c.retry(ctx, true, func(ctx context.Context) (*http.Response, error) {
+		return api.DeleteServiceAccountApplicationKey(ctx, serviceAccountID, appKeyID)
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting datadog service account application key: %w", err)
+	}
+
+	return nil
+}
+
+// createServiceAccount creates a service account holding
+// the given datadog roles
+func (c *datadogClient) createServiceAccount(ctx context.Context, email string, name string, roleIDs []string) (*datadogUser, error) {
+
+	body := datadogV2.ServiceAccountCreateRequest{
+		Data: datadogV2.ServiceAccountCreateData{
+			Attributes: datadogV2.ServiceAccountCreateAttributes{
+				Email:          email,
+				Name:           datadog.PtrString(name),
+				ServiceAccount: true,
+			},
+			Relationships: &datadogV2.UserRelationships{
+				Roles: &datadogV2.RelationshipToRoles{Data: roleRelationships(roleIDs)},
+			},
+			Type: datadogV2.USERSTYPE_USERS,
+		},
+	}
+
+	api := datadogV2.NewServiceAccountsApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV2.UserResponse
+	err := c.retry(ctx, false, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.CreateServiceAccount(ctx, body)
+		return httpResp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating datadog service account: %w", err)
+	}
+
+	return newDatadogUser(ddresp.GetData()), nil
+}
+
+// createUser creates a user holding the given datadog roles.
+// The user can only log in once it accepts an invitation.
+func (c *datadogClient) createUser(ctx context.Context, email string, name string, roleIDs []string) (*datadogUser, error) {
+
+	body := datadogV2.UserCreateRequest{
+		Data: datadogV2.UserCreateData{
+			Attributes: datadogV2.UserCreateAttributes{
+				Email: email,
+				Name:  datadog.PtrString(name),
+			},
+			Relationships: &datadogV2.UserRelationships{
+				Roles: &datadogV2.RelationshipToRoles{Data: roleRelationships(roleIDs)},
+			},
+			Type: datadogV2.USERSTYPE_USERS,
+		},
+	}
+
+	api := datadogV2.NewUsersApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV2.UserResponse
+	err := c.retry(ctx, false, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.CreateUser(ctx, body)
+		return httpResp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating datadog user: %w", err)
+	}
+
+	return newDatadogUser(ddresp.GetData()), nil
+}
+
+// inviteUser sends an invitation to log in to a user
+func (c *datadogClient) inviteUser(ctx context.Context, userID string) error {
+
+	body := datadogV2.UserInvitationsRequest{
+		Data: []datadogV2.UserInvitationData{
+			{
+				Relationships: datadogV2.UserInvitationRelationships{
+					User: userRelationship(userID),
+				},
+				Type: datadogV2.USERINVITATIONSTYPE_USER_INVITATIONS,
+			},
+		},
+	}
+
+	api := datadogV2.NewUsersApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	// a repeated invitation sends the user another email
+	err := c.retry(ctx, false, func(ctx context.Context) (httpResp *http.Response, err error) {
+		_, httpResp, err = api.SendInvitations(ctx, body)
+		return httpResp, err
+	})
+	if err != nil {
+		return fmt.Errorf("error inviting datadog user: %w", err)
+	}
+
+	return nil
+}
+
+// getUser returns a user or service account by ID
+func (c *datadogClient) getUser(ctx context.Context, userID string) (*datadogUser, error) {
+
+	api := datadogV2.NewUsersApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV2.UserResponse
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.GetUser(ctx, userID)
+		return httpResp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading datadog user: %w", err)
+	}
+
+	return newDatadogUser(ddresp.GetData()), nil
+}
+
+// getUserByEmail returns the user with the given email,
+// or nil if there is none
+func (c *datadogClient) getUserByEmail(ctx context.Context, email string) (*datadogUser, error) {
+
+	api := datadogV2.NewUsersApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	for page := int64(0); ; page++ {
+		params := datadogV2.NewListUsersOptionalParameters().
+			WithPageSize(listKeysPageSize).
+			WithPageNumber(page).
+			WithFilter(email)
+
+		var ddresp datadogV2.UsersResponse
+		err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+			ddresp, httpResp, err = api.ListUsers(ctx, *params)
+			return httpResp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing datadog users: %w", err)
+		}
+
+		// the filter also matches names and partial emails
+		for _, u := range ddresp.GetData() {
+			attributes := u.GetAttributes()
+			if strings.EqualFold(attributes.GetEmail(), email) {
+				return newDatadogUser(u), nil
+			}
+		}
+
+		if len(ddresp.GetData()) < listKeysPageSize {
+			return nil, nil
+		}
+	}
+}
+
+// enableUser enables a disabled user
+func (c *datadogClient) enableUser(ctx context.Context, userID string) error {
+
+	body := datadogV2.UserUpdateRequest{
+		Data: datadogV2.UserUpdateData{
+			Attributes: datadogV2.UserUpdateAttributes{
+				Disabled: datadog.PtrBool(false),
+			},
+			Id:   userID,
+			Type: datadogV2.USERSTYPE_USERS,
+		},
+	}
+
+	api := datadogV2.NewUsersApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		_, httpResp, err = api.UpdateUser(ctx, userID, body)
+		return httpResp, err
+	})
+	if err != nil {
+		return fmt.Errorf("error enabling datadog user: %w", err)
+	}
+
+	return nil
+}
+
+// addUserToRole adds a user to a datadog role
+func (c *datadogClient) addUserToRole(ctx context.Context, roleID string, userID string) error {
+
+	api := datadogV2.NewRolesApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		_, httpResp, err = api.AddUserToRole(ctx, roleID, userRelationship(userID))
+		return httpResp, err
+	})
+	if err != nil {
+		return fmt.Errorf("error adding datadog user to role %s: %w", roleID, err)
+	}
+
+	return nil
+}
+
+// removeUserFromRole removes a user from a datadog role
+func (c *datadogClient) removeUserFromRole(ctx context.Context, roleID string, userID string) error {
+
+	api := datadogV2.NewRolesApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		_, httpResp, err = api.RemoveUserFromRole(ctx, roleID, userRelationship(userID))
+		return httpResp, err
+	})
+	if err != nil {
+		return fmt.Errorf("error removing datadog user from role %s: %w", roleID, err)
+	}
+
+	return nil
+}
+
+// disableUser disables a user or service account. Datadog
+// doesn't delete users, so this is as far as they can be removed.
+func (c *datadogClient) disableUser(ctx context.Context, userID string) error {
+
+	api := datadogV2.NewUsersApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	err := c.retry(ctx, true, func(ctx context.Context) (*http.Response, error) {
+		return api.DisableUser(ctx, userID)
+	})
+	if err != nil {
+		return fmt.Errorf("error disabling datadog user: %w", err)
+	}
+
+	return nil
+}
+
+// validate checks that the configured API key is accepted by datadog
+func (c *datadogClient) validate(ctx context.Context) error {
+
+	api := datadogV1.NewAuthenticationApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV1.AuthenticationValidationResponse
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.Validate(ctx)
+		return httpResp, err
+	})
+	if err != nil {
+		return fmt.Errorf("error validating datadog API key: %w", err)
+	}
+	if !ddresp.GetValid() {
+		return errors.New("datadog API key is not valid")
+	}
+
+	return nil
+}
+
+func (c *datadogClient) getAPIKey(ctx context.Context, apiKeyID string) (*datadogKeyDetails, error) {
+
+	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV2.APIKeyResponse
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.GetAPIKey(ctx, apiKeyID)
+		return httpResp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading datadog API key: %w", err)
+	}
+
+	respData := ddresp.GetData()
+	attributes := respData.GetAttributes()
+
+	return &datadogKeyDetails{
+		ID:    respData.GetId(),
+		Name:  attributes.GetName(),
+		Last4: attributes.GetLast4(),
+	}, nil
+}
+
+func (c *datadogClient) getAppKey(ctx context.Context, appKeyID string) (*datadogKeyDetails, error) {
+
+	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var ddresp datadogV2.ApplicationKeyResponse
+	err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+		ddresp, httpResp, err = api.GetCurrentUserApplicationKey(ctx, appKeyID)
+		return httpResp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading datadog application key: %w", err)
+	}
+
+	respData := ddresp.GetData()
+	attributes := respData.GetAttributes()
+
+	return &datadogKeyDetails{
+		ID:     respData.GetId(),
+		Name:   attributes.GetName(),
+		Last4:  attributes.GetLast4(),
+		Scopes: attributes.GetScopes(),
+	}, nil
+}
+
+// listAPIKeys returns the API keys of the organization whose
+// names contain filter
+func (c *datadogClient) listAPIKeys(ctx context.Context, filter string) ([]*datadogKeyDetails, error) {
+
+	api := datadogV2.NewKeyManagementApi(c.APIClient)
+	ctx = c.withServerVariables(ctx)
+
+	var keys []*datadogKeyDetails
+	for page := int64(0); ; page++ {
+		params := datadogV2.NewListAPIKeysOptionalParameters().
+			WithPageSize(listKeysPageSize).
+			WithPageNumber(page).
+			WithFilter(filter)
+
+		var ddresp datadogV2.APIKeysResponse
+		err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+			ddresp, httpResp, err = api.ListAPIKeys(ctx, *params)
+			return httpResp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing datadog API keys: %w", err)
+		}
+
+		for _, k := range ddresp.GetData() {
+			attributes := k.GetAttributes()
+			keys = append(keys, &datadogKeyDetails{
+				ID:        k.GetId(),
+				Name:      attributes.GetName(),
+				Last4:     attributes.GetLast4(),
+				CreatedAt: parseCreatedAt(attributes.GetCreatedAt()),
+			})
+		}
+
+		if len(ddresp.GetData()) < listKeysPageSize {
+			return keys, nil
+		}
+	}
+}
+
+// listAppKeys returns the application keys of the organization,
+// including those owned by service accounts, whose names contain
+// filter
+func (c *datadogClient) listAppKeys(ctx context.Context, filter string) ([]*datadogKeyDetails, error) {
+
+	api := datadogV2.NewKeyManagementApi(c.APIClient)
+
+	return c.listAppKeyPages(ctx, func(ctx context.Context, page int64) (datadogV2.ListApplicationKeysResponse, *http.Response, error) {
+		params := datadogV2.NewListApplicationKeysOptionalParameters().
+			WithPageSize(listKeysPageSize).
+			WithPageNumber(page).
+			WithFilter(filter)
+		return api.ListApplicationKeys(ctx, *params)
+	})
+}
+
+// listCurrentUserAppKeys returns the application keys owned by the
+// current user whose names contain filter
+func (c *datadogClient) listCurrentUserAppKeys(ctx context.Context, filter string) ([]*datadogKeyDetails, error) {
+
+	api := datadogV2.NewKeyManagementApi(c.APIClient)
+
+	return c.listAppKeyPages(ctx, func(ctx context.Context, page int64) (datadogV2.ListApplicationKeysResponse, *http.Response, error) {
+		params := datadogV2.NewListCurrentUserApplicationKeysOptionalParameters().
+			WithPageSize(listKeysPageSize).
+			WithPageNumber(page).
+			WithFilter(filter)
+		return api.ListCurrentUserApplicationKeys(ctx, *params)
+	})
+}
+
+// listAppKeyPages collects the application keys returned
+// by list, page after page
+func (c *datadogClient) listAppKeyPages(ctx context.Context, list func(ctx context.Context, page int64) (datadogV2.ListApplicationKeysResponse, *http.Response, error)) ([]*datadogKeyDetails, error) {
+
+	ctx = c.withServerVariables(ctx)
+
+	var keys []*datadogKeyDetails
+	for page := int64(0); ; page++ {
+		var ddresp datadogV2.ListApplicationKeysResponse
+		err := c.retry(ctx, true, func(ctx context.Context) (httpResp *http.Response, err error) {
+			ddresp, httpResp, err = list(ctx, page)
+			return httpResp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing datadog application keys: %w", err)
+		}
+
+		for _, k := range ddresp.GetData() {
+			attributes := k.GetAttributes()
+			keys = append(keys, &datadogKeyDetails{
+				ID:        k.GetId(),
+				Name:      attributes.GetName(),
+				Last4:     attributes.GetLast4(),
+				Scopes:    attributes.GetScopes(),
+				CreatedAt: parseCreatedAt(attributes.GetCreatedAt()),
+			})
+		}
+
+		if len(ddresp.GetData()) < listKeysPageSize {
+			return keys, nil
+		}
+	}
+}
+
+// newDatadogUser converts a user returned by the datadog API,
+// including the IDs of the roles it holds
+func newDatadogUser(u datadogV2.User) *datadogUser {
+
+	attributes := u.GetAttributes()
+	user := &datadogUser{
+		ID:       u.GetId(),
+		Email:    attributes.GetEmail(),
+		Disabled: attributes.GetDisabled(),
+	}
+
+	relationships := u.GetRelationships()
+	roles := relationships.GetRoles()
+	for _, role := range roles.GetData() {
+		user.RoleIDs = append(user.RoleIDs, role.GetId())
+	}
+
+	return user
+}
+
+// roleRelationships returns the relationships to the given
+// datadog roles, as sent when creating a user or service account
+func roleRelationships(roleIDs []string) []datadogV2.RelationshipToRoleData {
+
+	roles := make([]datadogV2.RelationshipToRoleData, 0, len(roleIDs))
+	for _, id := range roleIDs {
+		role := datadogV2.NewRelationshipToRoleData()
+		role.SetId(id)
+		roles = append(roles, *role)
+	}
+
+	return roles
+}
+
+// userRelationship returns the relationship to a datadog user,
+// as sent when inviting the user or changing its roles
+func userRelationship(userID string) datadogV2.RelationshipToUser {
+
+	return datadogV2.RelationshipToUser{
+		Data: datadogV2.RelationshipToUserData{
+			Id:   userID,
+			Type: datadogV2.USERSTYPE_USERS,
+		},
+	}
+}
+
+// parseCreatedAt parses the creation date of a datadog key,
+// returning the zero time if it is missing or malformed
+func parseCreatedAt(createdAt string) time.Time {
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,802 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatadogKey is a key held by a testDatadogServer
+type testDatadogKey struct {
+	Name    string
+	Key     string
+	Scopes  []string
+	Created time.Time
+
+	// Owner is the service account owning an application key
+	Owner string
+}
+
+// testDatadogUser is a user or service account held by a
+// testDatadogServer
+type testDatadogUser struct {
+	Email          string
+	Name           string
+	Roles          []string
+	ServiceAccount bool
+	Disabled       bool
+	Invited        bool
+}
+
+// testDatadogServer is an in-memory stand-in for the datadog
+// key management API
+type testDatadogServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	apiKeys map[string]*testDatadogKey
+	appKeys map[string]*testDatadogKey
+	users   map[string]*testDatadogUser
+
+	// failAppKeyCreate makes application key creation fail
+	failAppKeyCreate bool
+
+	// deleteStatus, when set, is returned by every key deletion
+	deleteStatus int
+
+	// failures are returned, in order, by the next requests
+	failures []int
+	requests int
+}
+
+// newTestDatadogServer starts a testDatadogServer, seeded with the
+// test root keys, which is closed when the test finishes
+func newTestDatadogServer(t *testing.T) *testDatadogServer {
+	t.Helper()
+
+	s := &testDatadogServer{
+		apiKeys: map[string]*testDatadogKey{
+			APIKeyID: {Name: "root", Key: APIKey},
+		},
+		appKeys: map[string]*testDatadogKey{
+			AppKeyID: {Name: "root", Key: AppKey},
+		},
+		users: map[string]*testDatadogUser{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/validate", s.handleValidate)
+	mux.HandleFunc("GET /api/v2/api_keys", s.handleList("api_keys", s.apiKeys))
+	mux.HandleFunc("POST /api/v2/api_keys", s.handleCreate("api_keys", s.apiKeys))
+	mux.HandleFunc("GET /api/v2/api_keys/{id}", s.handleGet("api_keys", s.apiKeys))
+	mux.HandleFunc("DELETE /api/v2/api_keys/{id}", s.handleDelete(s.apiKeys))
+	mux.HandleFunc("GET /api/v2/application_keys", s.handleList("application_keys", s.appKeys))
+	mux.HandleFunc("GET /api/v2/current_user/application_keys", s.handleList("application_keys", s.appKeys))
+	mux.HandleFunc("POST /api/v2/current_user/application_keys", s.handleCreate("application_keys", s.appKeys))
+	mux.HandleFunc("GET /api/v2/current_user/application_keys/{id}", s.handleGet("application_keys", s.appKeys))
+	mux.HandleFunc("DELETE /api/v2/application_keys/{id}", s.handleDelete(s.appKeys))
+	mux.HandleFunc("POST /api/v2/service_accounts/{service_account_id}/application_keys", s.handleCreate("application_keys", s.appKeys))
+	mux.HandleFunc("DELETE /api/v2/service_accounts/{service_account_id}/application_keys/{id}", s.handleDelete(s.appKeys))
+	mux.HandleFunc("POST /api/v2/service_accounts", s.handleCreateUser(true))
+	mux.HandleFunc("POST /api/v2/users", s.handleCreateUser(false))
+	mux.HandleFunc("GET /api/v2/users", s.handleListUsers)
+	mux.HandleFunc("GET /api/v2/users/{id}", s.handleGetUser)
+	mux.HandleFunc("PATCH /api/v2/users/{id}", s.handleUpdateUser)
+	mux.HandleFunc("DELETE /api/v2/users/{id}", s.handleDisableUser)
+	mux.HandleFunc("POST /api/v2/user_invitations", s.handleInviteUser)
+	mux.HandleFunc("POST /api/v2/roles/{role_id}/users", s.handleUserRole(true))
+	mux.HandleFunc("DELETE /api/v2/roles/{role_id}/users", s.handleUserRole(false))
+
+	s.Server = httptest.NewServer(s.countRequests(mux))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// countRequests counts the requests made to the server and
+// fails them with the queued failures
+func (s *testDatadogServer) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		var status int
+		if len(s.failures) > 0 {
+			status, s.failures = s.failures[0], s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if status != 0 {
+			w.Header().Set("X-RateLimit-Reset", "0")
+			writeTestJSON(w, status, map[string]interface{}{
+				"errors": []string{http.StatusText(status)},
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// failNext queues failures for the next requests and
+// resets the request count
+func (s *testDatadogServer) failNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = statuses
+	s.requests = 0
+}
+
+func (s *testDatadogServer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.apiKeys {
+		if k.Key == r.Header.Get("DD-API-KEY") {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"valid": true})
+			return
+		}
+	}
+	writeTestJSON(w, http.StatusForbidden, map[string]interface{}{
+		"errors": []string{"Forbidden"},
+	})
+}
+
+func (s *testDatadogServer) handleCreate(keyType string, keys map[string]*testDatadogKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		fail := keyType == "application_keys" && s.failAppKeyCreate
+		s.mu.Unlock()
+		if fail {
+			writeTestJSON(w, http.StatusInternalServerError, map[string]interface{}{
+				"errors": []string{"Internal Server Error"},
+			})
+			return
+		}
+
+		var body struct {
+			Data struct {
+				Attributes struct {
+					Name   string   `json:"name"`
+					Scopes []string `json:"scopes"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, _ := uuid.GenerateUUID()
+		key, _ := uuid.GenerateUUID()
+		k := &testDatadogKey{
+			Name:    body.Data.Attributes.Name,
+			Key:     key,
+			Scopes:  body.Data.Attributes.Scopes,
+			Created: time.Now(),
+			Owner:   r.PathValue("service_account_id"),
+		}
+
+		s.mu.Lock()
+		keys[id] = k
+		s.mu.Unlock()
+
+		writeTestJSON(w, http.StatusCreated, testKeyResponse(keyType, id, k, true))
+	}
+}
+
+func (s *testDatadogServer) handleGet(keyType string, keys map[string]*testDatadogKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		id := r.PathValue("id")
+		k, ok := keys[id]
+		if !ok {
+			writeTestJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": []string{"Not found"},
+			})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, testKeyResponse(keyType, id, k, false))
+	}
+}
+
+// handleList returns every key whose name contains the filter
+// on the first page, ignoring the page size
+func (s *testDatadogServer) handleList(keyType string, keys map[string]*testDatadogKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		data := make([]interface{}, 0, len(keys))
+		if page := r.URL.Query().Get("page[number]"); page == "" || page == "0" {
+			for id, k := range keys {
+				if strings.Contains(k.Name, r.URL.Query().Get("filter")) {
+					data = append(data, testKeyResponse(keyType, id, k, false)["data"])
+				}
+			}
+		}
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+	}
+}
+
+func (s *testDatadogServer) handleDelete(keys map[string]*testDatadogKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.deleteStatus != 0 {
+			w.Header().Set("Retry-After", "0")
+			writeTestJSON(w, s.deleteStatus, map[string]interface{}{
+				"errors": []string{http.StatusText(s.deleteStatus)},
+			})
+			return
+		}
+
+		// service accounts can only delete their own keys
+		id := r.PathValue("id")
+		k, ok := keys[id]
+		if owner := r.PathValue("service_account_id"); ok && owner != "" && k.Owner != owner {
+			ok = false
+		}
+		if !ok {
+			writeTestJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": []string{"Not found"},
+			})
+			return
+		}
+		delete(keys, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *testDatadogServer) handleCreateUser(serviceAccount bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data struct {
+				Attributes struct {
+					Email string `json:"email"`
+					Name  string `json:"name"`
+				} `json:"attributes"`
+				Relationships struct {
+					Roles struct {
+						Data []struct {
+							ID string `json:"id"`
+						} `json:"data"`
+					} `json:"roles"`
+				} `json:"relationships"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, _ := uuid.GenerateUUID()
+		u := &testDatadogUser{
+			Email:          body.Data.Attributes.Email,
+			Name:           body.Data.Attributes.Name,
+			ServiceAccount: serviceAccount,
+		}
+		for _, role := range body.Data.Relationships.Roles.Data {
+			u.Roles = append(u.Roles, role.ID)
+		}
+
+		s.mu.Lock()
+		s.users[id] = u
+		s.mu.Unlock()
+
+		writeTestJSON(w, http.StatusCreated, testUserResponse(id, u))
+	}
+}
+
+// handleListUsers returns every user whose email or name
+// contains the filter on the first page
+func (s *testDatadogServer) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filter := r.URL.Query().Get("filter")
+	data := make([]interface{}, 0)
+	if page := r.URL.Query().Get("page[number]"); page == "" || page == "0" {
+		for id, u := range s.users {
+			if strings.Contains(u.Email, filter) || strings.Contains(u.Name, filter) {
+				data = append(data, testUserResponse(id, u)["data"])
+			}
+		}
+	}
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func (s *testDatadogServer) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	u, ok := s.users[id]
+	if !ok {
+		writeTestJSON(w, http.StatusNotFound, map[string]interface{}{
+			"errors": []string{"Not found"},
+		})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, testUserResponse(id, u))
+}
+
+func (s *testDatadogServer) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Data struct {
+			Attributes struct {
+				Disabled *bool `json:"disabled"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	u, ok := s.users[id]
+	if !ok {
+		writeTestJSON(w, http.StatusNotFound, map[string]interface{}{
+			"errors": []string{"Not found"},
+		})
+		return
+	}
+	if body.Data.Attributes.Disabled != nil {
+		u.Disabled = *body.Data.Attributes.Disabled
+	}
+	writeTestJSON(w, http.StatusOK, testUserResponse(id, u))
+}
+
+func (s *testDatadogServer) handleInviteUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Data []struct {
+			Relationships struct {
+				User struct {
+					Data struct {
+						ID string `json:"id"`
+					} `json:"data"`
+				} `json:"user"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, invitation := range body.Data {
+		if u, ok := s.users[invitation.Relationships.User.Data.ID]; ok {
+			u.Invited = true
+		}
+	}
+	writeTestJSON(w, http.StatusCreated, map[string]interface{}{"data": []interface{}{}})
+}
+
+// handleUserRole adds a user to, or removes it from, a role
+func (s *testDatadogServer) handleUserRole(add bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		u, ok := s.users[body.Data.ID]
+		if !ok {
+			writeTestJSON(w, http.StatusNotFound, map[string]interface{}{
+				"errors": []string{"Not found"},
+			})
+			return
+		}
+
+		roleID := r.PathValue("role_id")
+		roles := make([]string, 0, len(u.Roles)+1)
+		for _, id := range u.Roles {
+			if id != roleID {
+				roles = append(roles, id)
+			}
+		}
+		if add {
+			roles = append(roles, roleID)
+		}
+		u.Roles = roles
+
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{}})
+	}
+}
+
+func (s *testDatadogServer) handleDisableUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeTestJSON(w, http.StatusNotFound, map[string]interface{}{
+			"errors": []string{"Not found"},
+		})
+		return
+	}
+	u.Disabled = true
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addKey stores a new key directly in the server, returning its ID
+func (s *testDatadogServer) addKey(keys map[string]*testDatadogKey, name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, _ := uuid.GenerateUUID()
+	key, _ := uuid.GenerateUUID()
+	keys[id] = &testDatadogKey{Name: name, Key: key, Created: time.Now()}
+
+	return id
+}
+
+// keyCount returns the number of live API and App keys, excluding
+// the root keys the server was seeded with
+func (s *testDatadogServer) keyCount() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apiKeys, appKeys := len(s.apiKeys), len(s.appKeys)
+	if _, ok := s.apiKeys[APIKeyID]; ok {
+		apiKeys--
+	}
+	if _, ok := s.appKeys[AppKeyID]; ok {
+		appKeys--
+	}
+	return apiKeys, appKeys
+}
+
+func testKeyResponse(keyType string, id string, k *testDatadogKey, withKey bool) map[string]interface{} {
+	attributes := map[string]interface{}{
+		"name":       k.Name,
+		"last4":      k.Key[len(k.Key)-4:],
+		"created_at": k.Created.UTC().Format(time.RFC3339),
+	}
+	if withKey {
+		attributes["key"] = k.Key
+	}
+	if keyType == "application_keys" {
+		attributes["scopes"] = k.Scopes
+	}
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":         id,
+			"type":       keyType,
+			"attributes": attributes,
+		},
+	}
+}
+
+func testUserResponse(id string, u *testDatadogUser) map[string]interface{} {
+	roles := make([]interface{}, 0, len(u.Roles))
+	for _, roleID := range u.Roles {
+		roles = append(roles, map[string]interface{}{"id": roleID, "type": "roles"})
+	}
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   id,
+			"type": "users",
+			"attributes": map[string]interface{}{
+				"email":           u.Email,
+				"name":            u.Name,
+				"service_account": u.ServiceAccount,
+				"disabled":        u.Disabled,
+			},
+			"relationships": map[string]interface{}{
+				"roles": map[string]interface{}{"data": roles},
+			},
+		},
+	}
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// configureTestBackend writes a config pointing the backend at the given
+// test datadog server
+func configureTestBackend(t *testing.T, b logical.Backend, s logical.Storage, srv *testDatadogServer) {
+	t.Helper()
+
+	err := testConfigCreate(t, b, s, map[string]interface{}{
+		"api_key":    APIKey,
+		"api_key_id": APIKeyID,
+		"app_key":    AppKey,
+		"app_key_id": AppKeyID,
+		"api_url":    srv.URL,
+	})
+	require.NoError(t, err)
+}
+
+func TestNewClient(t *testing.T) {
+	t.Run("Site", func(t *testing.T) {
+		c, err := NewClient(&datadogConfig{APIKey: APIKey, AppKey: AppKey, Site: "datadoghq.eu"})
+		require.NoError(t, err)
+
+		u, err := c.GetConfig().ServerURLWithContext(c.withServerVariables(context.Background()), "v2.KeyManagementApi.CreateAPIKey")
+		require.NoError(t, err)
+		require.Equal(t, "https://api.datadoghq.eu", u)
+	})
+
+	t.Run("API URL", func(t *testing.T) {
+		c, err := NewClient(&datadogConfig{APIKey: APIKey, AppKey: AppKey, APIURL: "http://127.0.0.1:8080/"})
+		require.NoError(t, err)
+
+		u, err := c.GetConfig().ServerURLWithContext(c.withServerVariables(context.Background()), "v2.KeyManagementApi.CreateAPIKey")
+		require.NoError(t, err)
+		require.Equal(t, "http://127.0.0.1:8080", u)
+	})
+
+	t.Run("Invalid API URL", func(t *testing.T) {
+		_, err := NewClient(&datadogConfig{APIKey: APIKey, AppKey: AppKey, APIURL: "ftp://example.com"})
+		require.Error(t, err)
+	})
+}
+
+func TestVerifyConfig(t *testing.T) {
+	srv := newTestDatadogServer(t)
+
+	config := &datadogConfig{
+		APIKey:   APIKey,
+		APIKeyID: APIKeyID,
+		AppKey:   AppKey,
+		AppKeyID: AppKeyID,
+		APIURL:   srv.URL,
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		require.NoError(t, verifyConfig(context.Background(), config))
+	})
+
+	t.Run("Invalid API Key", func(t *testing.T) {
+		c := *config
+		c.APIKey = "invalid"
+		require.ErrorContains(t, verifyConfig(context.Background(), &c), "API key was rejected")
+	})
+
+	t.Run("Mismatched Key ID", func(t *testing.T) {
+		c := *config
+		c.AppKey = AppKey[:len(AppKey)-4] + "0000"
+		require.ErrorContains(t, verifyConfig(context.Background(), &c), "does not match")
+	})
+
+	t.Run("Unknown Key ID", func(t *testing.T) {
+		c := *config
+		c.APIKeyID = "unknown"
+		require.ErrorContains(t, verifyConfig(context.Background(), &c), "was not found")
+	})
+
+	t.Run("Missing Scope", func(t *testing.T) {
+		srv.appKeys[AppKeyID].Scopes = []string{"api_keys_write"}
+		defer func() { srv.appKeys[AppKeyID].Scopes = nil }()
+		require.ErrorContains(t, verifyConfig(context.Background(), config), "user_app_keys")
+	})
+}
+
+// TestDatadogKeys issues and revokes API and App keys against a
+// test datadog server
+func TestDatadogKeys(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	for _, path := range []string{apiKeyPath, appKeyPath, credsPath} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.IsError())
+		require.NotNil(t, resp.Secret)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	}
+
+	apiKeys, appKeys := srv.keyCount()
+	require.Zero(t, apiKeys)
+	require.Zero(t, appKeys)
+}
+
+func TestClassifyAPIError(t *testing.T) {
+	for _, tc := range []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	} {
+		err := wrapAPIError(&http.Response{StatusCode: tc.status}, datadog.GenericOpenAPIError{ErrorMessage: http.StatusText(tc.status)})
+		_, retryable := classifyAPIError(err)
+		require.Equal(t, tc.retryable, retryable, "status %d", tc.status)
+	}
+
+	_, retryable := classifyAPIError(errors.New("connection refused"))
+	require.True(t, retryable)
+}
+
+// TestRevokeFailures checks that revocation only fails for
+// errors which may succeed when retried
+func TestRevokeFailures(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	issue := func(t *testing.T) *logical.Secret {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      apiKeyPath + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		return resp.Secret
+	}
+
+	revoke := func(secret *logical.Secret) error {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   s,
+		})
+		return err
+	}
+
+	t.Run("Deleted In Datadog", func(t *testing.T) {
+		secret := issue(t)
+		keyID := secret.InternalData["api_key_id"].(string)
+		delete(srv.apiKeys, keyID)
+
+		require.NoError(t, revoke(secret))
+
+		k, err := getIssuedKey(context.Background(), s, keyID)
+		require.NoError(t, err)
+		require.Nil(t, k)
+	})
+
+	t.Run("Transient", func(t *testing.T) {
+		secret := issue(t)
+		keyID := secret.InternalData["api_key_id"].(string)
+
+		for _, status := range []int{http.StatusTooManyRequests, http.StatusBadGateway} {
+			srv.deleteStatus = status
+			require.Error(t, revoke(secret))
+		}
+
+		srv.deleteStatus = 0
+		require.NoError(t, revoke(secret))
+		require.NotContains(t, srv.apiKeys, keyID)
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		secret := issue(t)
+		keyID := secret.InternalData["api_key_id"].(string)
+
+		srv.deleteStatus = http.StatusForbidden
+		defer func() { srv.deleteStatus = 0 }()
+		require.NoError(t, revoke(secret))
+
+		k, err := getIssuedKey(context.Background(), s, keyID)
+		require.NoError(t, err)
+		require.NotNil(t, k)
+		require.Contains(t, k.RevocationError, "permissions")
+
+		tracked, err := trackedKeyIDs(context.Background(), s)
+		require.NoError(t, err)
+		require.NotContains(t, tracked, keyID)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	srv := newTestDatadogServer(t)
+
+	maxRetries := 2
+	c, err := NewClient(&datadogConfig{
+		APIKey:         APIKey,
+		AppKey:         AppKey,
+		APIURL:         srv.URL,
+		MaxRetries:     &maxRetries,
+		RequestTimeout: time.Second,
+	})
+	require.NoError(t, err)
+
+	t.Run("Rate Limited", func(t *testing.T) {
+		srv.failNext(http.StatusTooManyRequests, http.StatusTooManyRequests)
+		require.NoError(t, c.validate(context.Background()))
+		require.Equal(t, 3, srv.requests)
+	})
+
+	t.Run("Retries Exhausted", func(t *testing.T) {
+		srv.failNext(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		err := c.validate(context.Background())
+		require.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+		require.Equal(t, 3, srv.requests)
+	})
+
+	t.Run("Not Retryable", func(t *testing.T) {
+		srv.failNext(http.StatusForbidden)
+		err := c.validate(context.Background())
+		require.Equal(t, http.StatusForbidden, statusCode(err))
+		require.Equal(t, 1, srv.requests)
+	})
+
+	t.Run("Create Not Retried On Server Error", func(t *testing.T) {
+		srv.failNext(http.StatusInternalServerError)
+		_, err := c.createAPIKey(context.Background(), "test")
+		require.Equal(t, http.StatusInternalServerError, statusCode(err))
+		require.Equal(t, 1, srv.requests)
+
+		srv.failNext(http.StatusTooManyRequests)
+		_, err = c.createAPIKey(context.Background(), "test")
+		require.NoError(t, err)
+		require.Equal(t, 2, srv.requests)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	header := func(key, value string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set(key, value)
+		return resp
+	}
+
+	require.Equal(t, 5*time.Second, retryDelay(header("Retry-After", "5"), 0))
+	require.Equal(t, 7*time.Second, retryDelay(header("X-RateLimit-Reset", "7"), 0))
+	require.Equal(t, retryMaxDelay, retryDelay(header("X-RateLimit-Reset", "3600"), 0))
+
+	for attempt := 0; attempt < 10; attempt++ {
+		delay := retryDelay(nil, attempt)
+		require.LessOrEqual(t, delay, retryMaxDelay)
+		require.GreaterOrEqual(t, delay, min(retryBaseDelay<<attempt, retryMaxDelay)/2)
+	}
+}
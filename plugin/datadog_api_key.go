@@ -2,7 +2,6 @@ package plugin
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -34,33 +33,7 @@ func (b *datadogBackend) datadogAPIKey() *framework.Secret {
 }
 
 func (b *datadogBackend) apiKeyRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-
-	roleRaw, ok := req.Secret.InternalData["role"]
-	if !ok {
-		return nil, fmt.Errorf("secret is missing role internal data")
-	}
-
-	// get the role entry
-	role := roleRaw.(string)
-	roleEntry, err := b.getRole(ctx, req.Storage, role)
-	if err != nil {
-		return nil, fmt.Errorf("error retrieving role: %w", err)
-	}
-
-	if roleEntry == nil {
-		return nil, errors.New("error retrieving role: role is nil")
-	}
-
-	resp := &logical.Response{Secret: req.Secret}
-
-	if roleEntry.TTL > 0 {
-		resp.Secret.TTL = roleEntry.TTL
-	}
-	if roleEntry.MaxTTL > 0 {
-		resp.Secret.MaxTTL = roleEntry.MaxTTL
-	}
-
-	return resp, nil
+	return b.renewSecret(ctx, req, "api_key_id")
 }
 
 func (b *datadogBackend) apiKeyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -80,7 +53,7 @@ func (b *datadogBackend) apiKeyRevoke(ctx context.Context, req *logical.Request,
 		}
 	}
 
-	if err := deleteAPIKey(ctx, client, apiKeyID); err != nil {
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeAPIKey, apiKeyID, ""); err != nil {
 		return nil, fmt.Errorf("error revoking API Key: %w", err)
 	}
 	return nil, nil
@@ -2,7 +2,6 @@ package plugin
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -33,32 +32,7 @@ func (b *datadogBackend) datadogAppKey() *framework.Secret {
 }
 
 func (b *datadogBackend) appKeyRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	roleRaw, ok := req.Secret.InternalData["role"]
-	if !ok {
-		return nil, fmt.Errorf("secret is missing role internal data")
-	}
-
-	// get the role entry
-	role := roleRaw.(string)
-	roleEntry, err := b.getRole(ctx, req.Storage, role)
-	if err != nil {
-		return nil, fmt.Errorf("error retrieving role: %w", err)
-	}
-
-	if roleEntry == nil {
-		return nil, errors.New("error retrieving role: role is nil")
-	}
-
-	resp := &logical.Response{Secret: req.Secret}
-
-	if roleEntry.TTL > 0 {
-		resp.Secret.TTL = roleEntry.TTL
-	}
-	if roleEntry.MaxTTL > 0 {
-		resp.Secret.MaxTTL = roleEntry.MaxTTL
-	}
-
-	return resp, nil
+	return b.renewSecret(ctx, req, "app_key_id")
 }
 
 func (b *datadogBackend) appKeyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -78,7 +52,10 @@ func (b *datadogBackend) appKeyRevoke(ctx context.Context, req *logical.Request,
 		}
 	}
 
-	if err := deleteAppKey(ctx, client, appKeyID); err != nil {
+	// keys issued before service accounts were supported have none
+	serviceAccountID, _ := req.Secret.InternalData["service_account_id"].(string)
+
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeAppKey, appKeyID, serviceAccountID); err != nil {
 		return nil, fmt.Errorf("error revoking Application Key: %w", err)
 	}
 	return nil, nil
@@ -103,3 +80,26 @@ func deleteAppKey(ctx context.Context, c *datadogClient, appKeyID string) error
 
 	return nil
 }
+
+// createRoleAppKey creates an application key for a role, owned by
+// the service account of the role if it has one, or otherwise by the
+// owner of the root application key
+func createRoleAppKey(ctx context.Context, c *datadogClient, roleEntry *datadogRoleEntry, name string) (*datadogAppKey, error) {
+
+	if roleEntry.ServiceAccountID != "" {
+		return c.createServiceAccountAppKey(ctx, roleEntry.ServiceAccountID, name, roleEntry.AppKeyScopes)
+	}
+
+	return createAppKey(ctx, c, name, roleEntry.AppKeyScopes)
+}
+
+// deleteRoleAppKey deletes an application key created by
+// createRoleAppKey
+func deleteRoleAppKey(ctx context.Context, c *datadogClient, serviceAccountID string, appKeyID string) error {
+
+	if serviceAccountID != "" {
+		return c.deleteServiceAccountAppKey(ctx, serviceAccountID, appKeyID)
+	}
+
+	return deleteAppKey(ctx, c, appKeyID)
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	datadogCredsType = "datadog_creds"
+)
+
+func (b *datadogBackend) datadogCreds() *framework.Secret {
+	return &framework.Secret{
+		Type: datadogCredsType,
+		Fields: map[string]*framework.FieldSchema{
+			"api_key": {
+				Type:        framework.TypeString,
+				Description: "datadog API Key",
+			},
+			"app_key": {
+				Type:        framework.TypeString,
+				Description: "datadog Application Key",
+			},
+		},
+		Renew:  b.credsRenew,
+		Revoke: b.credsRevoke,
+	}
+}
+
+func (b *datadogBackend) credsRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.renewSecret(ctx, req, "api_key_id", "app_key_id")
+}
+
+// credsRevoke deletes both the API and Application Key of a
+// datadog_creds secret
+func (b *datadogBackend) credsRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	apiKeyID, ok := req.Secret.InternalData["api_key_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for apiKeyID in secret internal data")
+	}
+
+	appKeyID, ok := req.Secret.InternalData["app_key_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for appKeyID in secret internal data")
+	}
+
+	// keys issued before service accounts were supported have none
+	serviceAccountID, _ := req.Secret.InternalData["service_account_id"].(string)
+
+	// attempt both deletions so that one failure doesn't
+	// leave the other key behind
+	var errs error
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeAppKey, appKeyID, serviceAccountID); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("error revoking Application Key: %w", err))
+	}
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeAPIKey, apiKeyID, ""); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("error revoking API Key: %w", err))
+	}
+
+	return nil, errs
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	datadogElevationType = "datadog_elevation"
+)
+
+func (b *datadogBackend) datadogElevation() *framework.Secret {
+	return &framework.Secret{
+		Type: datadogElevationType,
+		Fields: map[string]*framework.FieldSchema{
+			"user_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the elevated datadog user",
+			},
+			"email": {
+				Type:        framework.TypeString,
+				Description: "Email of the elevated datadog user",
+			},
+			"datadog_role_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the datadog role added to the user",
+			},
+		},
+		Renew:  b.elevationRenew,
+		Revoke: b.elevationRevoke,
+	}
+}
+
+func (b *datadogBackend) elevationRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.renewSecret(ctx, req, "elevation_id")
+}
+
+// elevationRevoke removes the datadog role of a datadog_elevation
+// secret from its user
+func (b *datadogBackend) elevationRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	elevationID, ok := req.Secret.InternalData["elevation_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for elevationID in secret internal data")
+	}
+
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeElevation, elevationID, ""); err != nil {
+		return nil, fmt.Errorf("error revoking elevation: %w", err)
+	}
+	return nil, nil
+}
+
+// elevationID identifies the elevation of a user to a datadog
+// role. Its issued key record is keyed by this ID, so a user can
+// only hold one elevation to a role at a time.
+func elevationID(userID string, datadogRoleID string) string {
+	return userID + ":" + datadogRoleID
+}
+
+// deleteElevation removes the datadog role of an elevation
+// from its user
+func deleteElevation(ctx context.Context, c *datadogClient, elevationID string) error {
+
+	userID, datadogRoleID, ok := strings.Cut(elevationID, ":")
+	if !ok {
+		return fmt.Errorf("invalid elevation ID %q", elevationID)
+	}
+
+	return c.removeUserFromRole(ctx, datadogRoleID, userID)
+}
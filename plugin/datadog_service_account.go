@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	datadogServiceAccountType = "datadog_service_account"
+)
+
+func (b *datadogBackend) datadogServiceAccount() *framework.Secret {
+	return &framework.Secret{
+		Type: datadogServiceAccountType,
+		Fields: map[string]*framework.FieldSchema{
+			"service_account_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the datadog service account",
+			},
+			"email": {
+				Type:        framework.TypeString,
+				Description: "Email of the datadog service account",
+			},
+			"app_key": {
+				Type:        framework.TypeString,
+				Description: "datadog Application Key owned by the service account",
+			},
+		},
+		Renew:  b.serviceAccountRenew,
+		Revoke: b.serviceAccountRevoke,
+	}
+}
+
+func (b *datadogBackend) serviceAccountRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.renewSecret(ctx, req, "app_key_id")
+}
+
+// serviceAccountRevoke deletes the application key of a
+// datadog_service_account secret and disables its service account
+func (b *datadogBackend) serviceAccountRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	appKeyID, ok := req.Secret.InternalData["app_key_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for appKeyID in secret internal data")
+	}
+
+	serviceAccountID, ok := req.Secret.InternalData["service_account_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for serviceAccountID in secret internal data")
+	}
+
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeServiceAccount, appKeyID, serviceAccountID); err != nil {
+		return nil, fmt.Errorf("error revoking service account: %w", err)
+	}
+	return nil, nil
+}
+
+// deleteServiceAccount deletes the application key of a service
+// account issued from a role, then disables the service account.
+// The key is deleted first as it would otherwise outlive a failure
+// to disable the account.
+func deleteServiceAccount(ctx context.Context, c *datadogClient, serviceAccountID string, appKeyID string) error {
+
+	err := c.deleteServiceAccountAppKey(ctx, serviceAccountID, appKeyID)
+	if err != nil && statusCode(err) != http.StatusNotFound {
+		return err
+	}
+
+	return c.disableUser(ctx, serviceAccountID)
+}
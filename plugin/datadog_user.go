@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	datadogUserType = "datadog_user"
+
+	managedUserStoragePrefix = "managed-users/"
+)
+
+// managedUser records a datadog user created by the backend.
+// Datadog can't delete users, so revoked users are disabled
+// and enabled again when their entity next requests access.
+type managedUser struct {
+	UserID     string    `json:"user_id"`
+	Email      string    `json:"email"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+func (b *datadogBackend) datadogUser() *framework.Secret {
+	return &framework.Secret{
+		Type: datadogUserType,
+		Fields: map[string]*framework.FieldSchema{
+			"user_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the datadog user",
+			},
+			"email": {
+				Type:        framework.TypeString,
+				Description: "Email of the datadog user",
+			},
+		},
+		Renew:  b.userRenew,
+		Revoke: b.userRevoke,
+	}
+}
+
+func (b *datadogBackend) userRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.renewSecret(ctx, req, "user_id")
+}
+
+// userRevoke removes the datadog user of a datadog_user
+// secret from its roles and disables it
+func (b *datadogBackend) userRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	userID, ok := req.Secret.InternalData["user_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for userID in secret internal data")
+	}
+
+	if err := b.revokeIssuedKey(ctx, req.Storage, client, credentialTypeUser, userID, ""); err != nil {
+		return nil, fmt.Errorf("error revoking user: %w", err)
+	}
+	return nil, nil
+}
+
+// deleteUser removes a user issued from a role from all of its
+// datadog roles, so that it doesn't regain them if it is enabled
+// outside of Vault, then disables it
+func deleteUser(ctx context.Context, c *datadogClient, userID string) error {
+
+	user, err := c.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, roleID := range user.RoleIDs {
+		err := c.removeUserFromRole(ctx, roleID, userID)
+		if err != nil && statusCode(err) != http.StatusNotFound {
+			return err
+		}
+	}
+
+	return c.disableUser(ctx, userID)
+}
+
+// setUserRoles makes a user hold exactly the given datadog roles
+func setUserRoles(ctx context.Context, c *datadogClient, user *datadogUser, roleIDs []string) error {
+
+	for _, roleID := range user.RoleIDs {
+		if contains(roleIDs, roleID) {
+			continue
+		}
+		err := c.removeUserFromRole(ctx, roleID, user.ID)
+		if err != nil && statusCode(err) != http.StatusNotFound {
+			return err
+		}
+	}
+
+	for _, roleID := range roleIDs {
+		if contains(user.RoleIDs, roleID) {
+			continue
+		}
+		if err := c.addUserToRole(ctx, roleID, user.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getManagedUser(ctx context.Context, s logical.Storage, userID string) (*managedUser, error) {
+
+	entry, err := s.Get(ctx, managedUserStoragePrefix+userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var u managedUser
+	if err := entry.DecodeJSON(&u); err != nil {
+		return nil, fmt.Errorf("error reading managed user: %w", err)
+	}
+
+	return &u, nil
+}
+
+func putManagedUser(ctx context.Context, s logical.Storage, u *managedUser) error {
+
+	entry, err := logical.StorageEntryJSON(managedUserStoragePrefix+u.UserID, u)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
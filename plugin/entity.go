@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"net/mail"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// requester is the Vault entity requesting a datadog
+// user or elevation from a role
+type requester struct {
+	Email string
+	Name  string
+}
+
+// getRequester returns the email and name of the entity of a
+// request. The email is read from the entity metadata key of the
+// role or, failing that, from the first alias of the entity whose
+// name is an email address. An error response is returned when
+// the request has no entity or no email can be found.
+func (b *datadogBackend) getRequester(req *logical.Request, roleEntry *datadogRoleEntry) (*requester, *logical.Response, error) {
+
+	if req.EntityID == "" {
+		return nil, logical.ErrorResponse("role %q only issues credentials to Vault identity entities", roleEntry.Name), logical.ErrPermissionDenied
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if entity == nil {
+		return nil, logical.ErrorResponse("entity %q not found", req.EntityID), nil
+	}
+
+	r := &requester{
+		Name: entity.Name,
+	}
+	if r.Name == "" {
+		r.Name = req.DisplayName
+	}
+
+	if email := entity.Metadata[roleEntry.emailMetadataKey()]; isEmail(email) {
+		r.Email = email
+		return r, nil, nil
+	}
+
+	for _, alias := range entity.Aliases {
+		if isEmail(alias.Name) {
+			r.Email = alias.Name
+			return r, nil, nil
+		}
+	}
+
+	return nil, logical.ErrorResponse("entity %q has no email in its %q metadata or its aliases", req.EntityID, roleEntry.emailMetadataKey()), nil
+}
+
+// isEmail reports whether s is a bare email address
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
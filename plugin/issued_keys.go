@@ -0,0 +1,316 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	issuedKeyStoragePrefix = "issued-keys/"
+)
+
+// issuedKey records who requested a dynamic datadog key
+// and when its lease expires
+type issuedKey struct {
+	KeyID         string    `json:"key_id"`
+	KeyType       string    `json:"key_type"`
+	Role          string    `json:"role"`
+	LeaseID       string    `json:"lease_id"`
+	EntityID      string    `json:"entity_id"`
+	DisplayName   string    `json:"display_name"`
+	MountAccessor string    `json:"mount_accessor"`
+	IssueTime     time.Time `json:"issue_time"`
+	ExpireTime    time.Time `json:"expire_time"`
+	Revoked       bool      `json:"revoked"`
+
+	// ServiceAccountID is the owner of an application key
+	// issued from a role with a service account, or the
+	// service account issued along with the key
+	ServiceAccountID string `json:"service_account_id,omitempty"`
+
+	// RevocationError is set when the lease of the key ended
+	// but the key could not be deleted from datadog
+	RevocationError string `json:"revocation_error,omitempty"`
+}
+
+// newIssuedKey returns the record of a key issued from a role
+// for the requester of req
+func (b *datadogBackend) newIssuedKey(req *logical.Request, roleEntry *datadogRoleEntry, keyType string, keyID string) *issuedKey {
+
+	now := time.Now().UTC()
+
+	k := &issuedKey{
+		KeyID:         keyID,
+		KeyType:       keyType,
+		Role:          roleEntry.Name,
+		EntityID:      req.EntityID,
+		DisplayName:   req.DisplayName,
+		MountAccessor: req.MountAccessor,
+		IssueTime:     now,
+		ExpireTime:    now.Add(b.leaseTTL(roleEntry)),
+	}
+
+	if keyType == credentialTypeAppKey {
+		k.ServiceAccountID = roleEntry.ServiceAccountID
+	}
+
+	return k
+}
+
+// active reports whether the lease of the key has not ended.
+// Keys of a revoke-all remain recorded until their lease is revoked,
+// and keys whose revocation failed until they are tidied.
+func (k *issuedKey) active() bool {
+	return !k.Revoked && k.RevocationError == ""
+}
+
+// internalData adds the requester of the key to the
+// internal data of its secret
+func (k *issuedKey) internalData(data map[string]interface{}) map[string]interface{} {
+
+	data["entity_id"] = k.EntityID
+	data["display_name"] = k.DisplayName
+	data["mount_accessor"] = k.MountAccessor
+	data["issue_time"] = k.IssueTime.Format(time.RFC3339)
+
+	return data
+}
+
+// leaseTTL returns the lease duration of credentials issued from a role
+func (b *datadogBackend) leaseTTL(roleEntry *datadogRoleEntry) time.Duration {
+
+	ttl := roleEntry.TTL
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+
+	maxTTL := roleEntry.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = b.System().MaxLeaseTTL()
+	}
+
+	if maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	return ttl
+}
+
+// renewSecret renews the lease of a secret with the TTLs of its role
+// and moves the expiry of the issued keys whose IDs are stored in
+// the given internal data fields of the secret
+func (b *datadogBackend) renewSecret(ctx context.Context, req *logical.Request, idFields ...string) (*logical.Response, error) {
+
+	role, ok := req.Secret.InternalData["role"].(string)
+	if !ok {
+		return nil, fmt.Errorf("secret is missing role internal data")
+	}
+
+	roleEntry, err := b.getRole(ctx, req.Storage, role)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return nil, errors.New("error retrieving role: role is nil")
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+
+	if roleEntry.TTL > 0 {
+		resp.Secret.TTL = roleEntry.TTL
+	}
+	if roleEntry.MaxTTL > 0 {
+		resp.Secret.MaxTTL = roleEntry.MaxTTL
+	}
+
+	for _, field := range idFields {
+		if keyID, ok := req.Secret.InternalData[field].(string); ok {
+			if err := b.renewIssuedKey(ctx, req.Storage, keyID, req.Secret.LeaseID, roleEntry); err != nil {
+				return nil, fmt.Errorf("error updating issued key record: %w", err)
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// renewIssuedKey moves the expiry of an issued key forward after
+// its lease was renewed, and records the lease ID which is not
+// known when the key is issued
+func (b *datadogBackend) renewIssuedKey(ctx context.Context, s logical.Storage, keyID string, leaseID string, roleEntry *datadogRoleEntry) error {
+
+	k, err := getIssuedKey(ctx, s, keyID)
+	if err != nil {
+		return err
+	}
+
+	// keys issued before they were recorded have nothing to renew
+	if k == nil {
+		return nil
+	}
+
+	if k.Revoked {
+		return fmt.Errorf("key %q was revoked along with all keys of role %q", keyID, k.Role)
+	}
+
+	if leaseID != "" {
+		k.LeaseID = leaseID
+	}
+	k.ExpireTime = time.Now().UTC().Add(b.leaseTTL(roleEntry))
+
+	return b.putIssuedKey(ctx, s, k)
+}
+
+// revokeIssuedKey deletes an issued key from datadog and removes
+// its record. Keys already deleted by a revoke-all of their role
+// only have their record removed, and keys already deleted from
+// datadog are treated as revoked.
+//
+// Only transient failures are returned, so that Vault retries the
+// revocation. Otherwise the lease ends and the record is kept with
+// the error, leaving the key to be deleted by a tidy.
+func (b *datadogBackend) revokeIssuedKey(ctx context.Context, s logical.Storage, c *datadogClient, keyType string, keyID string, serviceAccountID string) error {
+
+	k, err := getIssuedKey(ctx, s, keyID)
+	if err != nil {
+		return err
+	}
+
+	if k != nil && k.Revoked {
+		return b.deleteIssuedKey(ctx, s, keyID)
+	}
+
+	err = deleteIssuedDatadogKey(ctx, c, keyType, keyID, serviceAccountID)
+	if err != nil && statusCode(err) != http.StatusNotFound {
+		reason, retryable := classifyAPIError(err)
+		if retryable {
+			return fmt.Errorf("%s, revocation will be retried: %w", reason, err)
+		}
+
+		b.Logger().Error("key could not be deleted and will not be retried", "key_id", keyID, "key_type", keyType, "reason", reason, "error", err)
+
+		// keys issued before they were recorded get a record
+		// so that the failure is visible
+		if k == nil {
+			k = &issuedKey{
+				KeyID:            keyID,
+				KeyType:          keyType,
+				ServiceAccountID: serviceAccountID,
+			}
+		}
+		k.RevocationError = fmt.Sprintf("%s: %s", reason, err)
+
+		return b.putIssuedKey(ctx, s, k)
+	}
+
+	return b.deleteIssuedKey(ctx, s, keyID)
+}
+
+// deleteIssuedDatadogKey deletes the datadog key of an issued
+// key of the given type
+func deleteIssuedDatadogKey(ctx context.Context, c *datadogClient, keyType string, keyID string, serviceAccountID string) error {
+
+	switch keyType {
+	case credentialTypeAPIKey:
+		return deleteAPIKey(ctx, c, keyID)
+	case credentialTypeAppKey:
+		return deleteRoleAppKey(ctx, c, serviceAccountID, keyID)
+	case credentialTypeServiceAccount:
+		return deleteServiceAccount(ctx, c, serviceAccountID, keyID)
+	case credentialTypeUser:
+		return deleteUser(ctx, c, keyID)
+	case credentialTypeElevation:
+		return deleteElevation(ctx, c, keyID)
+	default:
+		return fmt.Errorf("unknown key type %q", keyType)
+	}
+}
+
+func getIssuedKey(ctx context.Context, s logical.Storage, keyID string) (*issuedKey, error) {
+
+	if keyID == "" {
+		return nil, fmt.Errorf("missing key ID")
+	}
+
+	entry, err := s.Get(ctx, issuedKeyStoragePrefix+keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var k issuedKey
+	if err := entry.DecodeJSON(&k); err != nil {
+		return nil, fmt.Errorf("error reading issued key: %w", err)
+	}
+
+	return &k, nil
+}
+
+// listIssuedKeys returns the keys issued by the backend
+// ordered by the time they were issued
+func listIssuedKeys(ctx context.Context, s logical.Storage) ([]*issuedKey, error) {
+
+	keyIDs, err := s.List(ctx, issuedKeyStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*issuedKey, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		k, err := getIssuedKey(ctx, s, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].IssueTime.Before(keys[j].IssueTime)
+	})
+
+	return keys, nil
+}
+
+// putIssuedKey stores the record of an issued key and
+// updates the active keys
+func (b *datadogBackend) putIssuedKey(ctx context.Context, s logical.Storage, k *issuedKey) error {
+
+	entry, err := logical.StorageEntryJSON(issuedKeyStoragePrefix+k.KeyID, k)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	b.trackActiveKey(k.KeyID, k.Role, k.active())
+
+	return nil
+}
+
+// deleteIssuedKey removes the record of an issued key and
+// updates the active keys
+func (b *datadogBackend) deleteIssuedKey(ctx context.Context, s logical.Storage, keyID string) error {
+
+	if err := s.Delete(ctx, issuedKeyStoragePrefix+keyID); err != nil {
+		return err
+	}
+
+	b.trackActiveKey(keyID, "", false)
+
+	return nil
+}
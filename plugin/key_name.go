@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/template"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// defaultKeyNameTemplate names keys <role>-<uuid>
+	defaultKeyNameTemplate = `{{ .RoleName }}-{{ uuid }}`
+
+	// maxKeyNameLength is the longest key name datadog accepts
+	maxKeyNameLength = 255
+
+	// maxKeyNamePrefixLength leaves room in key names
+	// for the name templates of roles
+	maxKeyNamePrefixLength = 64
+)
+
+// keyNameData is the data available to key name templates
+type keyNameData struct {
+	RoleName    string
+	DisplayName string
+	EntityID    string
+	MountPath   string
+}
+
+// generateKeyName renders the name of a key issued from a role,
+// prefixed with the key name prefix of the mount
+func generateKeyName(ctx context.Context, req *logical.Request, roleEntry *datadogRoleEntry) (string, error) {
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return "", fmt.Errorf("error getting config: %w", err)
+	}
+
+	prefix := ""
+	if config != nil {
+		prefix = config.KeyNamePrefix
+	}
+
+	return renderKeyName(prefix, roleEntry.NameTemplate, keyNameData{
+		RoleName:    roleEntry.Name,
+		DisplayName: req.DisplayName,
+		EntityID:    req.EntityID,
+		MountPath:   req.MountPoint,
+	})
+}
+
+// validateKeyNameTemplate checks that a name template parses and
+// renders a name datadog accepts for representative request data
+func validateKeyNameTemplate(nameTemplate string, roleName string) error {
+
+	_, err := renderKeyName("", nameTemplate, keyNameData{
+		RoleName:    roleName,
+		DisplayName: "token-display-name",
+		EntityID:    "00000000-0000-0000-0000-000000000000",
+		MountPath:   "datadog/",
+	})
+	return err
+}
+
+func renderKeyName(prefix string, nameTemplate string, data keyNameData) (string, error) {
+
+	if nameTemplate == "" {
+		nameTemplate = defaultKeyNameTemplate
+	}
+
+	tmpl, err := template.NewTemplate(template.Template(nameTemplate))
+	if err != nil {
+		return "", fmt.Errorf("invalid name_template: %w", err)
+	}
+
+	name, err := tmpl.Generate(data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering name_template: %w", err)
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("name_template rendered an empty key name")
+	}
+
+	name = prefix + name
+
+	if len(name) > maxKeyNameLength {
+		return "", fmt.Errorf("name_template rendered a key name of %d characters, the maximum is %d", len(name), maxKeyNameLength)
+	}
+
+	return name, nil
+}
+
+// generateEmail renders the email of a service account
+// created for a role
+func generateEmail(req *logical.Request, roleEntry *datadogRoleEntry) (string, error) {
+
+	return renderEmail(roleEntry.EmailTemplate, keyNameData{
+		RoleName:    roleEntry.Name,
+		DisplayName: req.DisplayName,
+		EntityID:    req.EntityID,
+		MountPath:   req.MountPoint,
+	})
+}
+
+// validateEmailTemplate checks that an email template parses and
+// renders a valid email address for representative request data
+func validateEmailTemplate(emailTemplate string, roleName string) error {
+
+	_, err := renderEmail(emailTemplate, keyNameData{
+		RoleName:    roleName,
+		DisplayName: "token-display-name",
+		EntityID:    "00000000-0000-0000-0000-000000000000",
+		MountPath:   "datadog/",
+	})
+	return err
+}
+
+func renderEmail(emailTemplate string, data keyNameData) (string, error) {
+
+	if emailTemplate == "" {
+		return "", fmt.Errorf("email_template is required")
+	}
+
+	tmpl, err := template.NewTemplate(template.Template(emailTemplate))
+	if err != nil {
+		return "", fmt.Errorf("invalid email_template: %w", err)
+	}
+
+	email, err := tmpl.Generate(data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering email_template: %w", err)
+	}
+
+	// datadog expects a bare address, without a display name
+	if !isEmail(email) {
+		return "", fmt.Errorf("email_template rendered %q, which is not a valid email address", email)
+	}
+
+	return email, nil
+}
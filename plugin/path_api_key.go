@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -48,28 +47,57 @@ func (b *datadogBackend) pathAPIKeyRead(ctx context.Context, req *logical.Reques
 		return nil, fmt.Errorf("error retrieving role: %w", err)
 	}
 
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	if !roleEntry.allowsCredentialType(credentialTypeAPIKey) {
+		return logical.ErrorResponse("role %q is not permitted to issue API keys", roleName), logical.ErrPermissionDenied
+	}
+
+	if roleEntry.Disabled {
+		return logical.ErrorResponse("role %q is disabled", roleName), logical.ErrPermissionDenied
+	}
+
+	if resp, err := b.limitKeyCreation(ctx, req.Storage, roleEntry, 1); resp != nil || err != nil {
+		return resp, err
+	}
+
+	release, limitResp, err := b.limitActiveKeys(ctx, req.Storage, roleEntry, 1)
+	if limitResp != nil || err != nil {
+		return limitResp, err
+	}
+	defer release()
+
 	client, err := b.getClient(ctx, req.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("error getting client: %w", err)
 	}
 
-	uuid, err := uuid.GenerateUUID()
+	keyName, err := generateKeyName(ctx, req, roleEntry)
 	if err != nil {
-		return nil, fmt.Errorf("error generating UUID for API key name: %w", err)
+		return logical.ErrorResponse(err.Error()), nil
 	}
-	keyName := roleName + "-" + uuid
 
 	apiKey, err := createAPIKey(ctx, client, keyName)
 	if err != nil {
 		return nil, fmt.Errorf("error creating datadog API key: %w", err)
 	}
 
+	issued := b.newIssuedKey(req, roleEntry, credentialTypeAPIKey, apiKey.APIKeyID)
+	if err := b.putIssuedKey(ctx, req.Storage, issued); err != nil {
+		if rollbackErr := deleteAPIKey(ctx, client, apiKey.APIKeyID); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog API key", "api_key_id", apiKey.APIKeyID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error recording issued API key: %w", err)
+	}
+
 	resp := b.Secret(datadogAPIKeyType).Response(map[string]interface{}{
 		"api_key": apiKey.APIKey,
-	}, map[string]interface{}{
+	}, issued.internalData(map[string]interface{}{
 		"api_key_id": apiKey.APIKeyID,
 		"role":       roleEntry.Name,
-	})
+	}))
 
 	if roleEntry.TTL > 0 {
 		resp.Secret.TTL = roleEntry.TTL
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -48,28 +47,58 @@ func (b *datadogBackend) pathAppKeyRead(ctx context.Context, req *logical.Reques
 		return nil, fmt.Errorf("error retrieving role: %w", err)
 	}
 
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	if !roleEntry.allowsCredentialType(credentialTypeAppKey) {
+		return logical.ErrorResponse("role %q is not permitted to issue application keys", roleName), logical.ErrPermissionDenied
+	}
+
+	if roleEntry.Disabled {
+		return logical.ErrorResponse("role %q is disabled", roleName), logical.ErrPermissionDenied
+	}
+
+	if resp, err := b.limitKeyCreation(ctx, req.Storage, roleEntry, 1); resp != nil || err != nil {
+		return resp, err
+	}
+
+	release, limitResp, err := b.limitActiveKeys(ctx, req.Storage, roleEntry, 1)
+	if limitResp != nil || err != nil {
+		return limitResp, err
+	}
+	defer release()
+
 	client, err := b.getClient(ctx, req.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("error getting client: %w", err)
 	}
 
-	uuid, err := uuid.GenerateUUID()
+	keyName, err := generateKeyName(ctx, req, roleEntry)
 	if err != nil {
-		return nil, fmt.Errorf("error generating UUID for App key name: %w", err)
+		return logical.ErrorResponse(err.Error()), nil
 	}
-	keyName := roleName + "-" + uuid
 
-	appKey, err := createAppKey(ctx, client, keyName, roleEntry.AppKeyScopes)
+	appKey, err := createRoleAppKey(ctx, client, roleEntry, keyName)
 	if err != nil {
 		return nil, fmt.Errorf("error creating datadog application key: %w", err)
 	}
 
+	issued := b.newIssuedKey(req, roleEntry, credentialTypeAppKey, appKey.AppKeyID)
+	if err := b.putIssuedKey(ctx, req.Storage, issued); err != nil {
+		if rollbackErr := deleteRoleAppKey(ctx, client, roleEntry.ServiceAccountID, appKey.AppKeyID); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog application key", "app_key_id", appKey.AppKeyID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error recording issued application key: %w", err)
+	}
+
 	resp := b.Secret(datadogAppKeyType).Response(map[string]interface{}{
 		"app_key": appKey.AppKey,
-	}, map[string]interface{}{
-		"app_key_id": appKey.AppKeyID,
-		"role":       roleEntry.Name,
-	})
+	}, issued.internalData(map[string]interface{}{
+		"app_key_id":         appKey.AppKeyID,
+		"role":               roleEntry.Name,
+		"service_account_id": roleEntry.ServiceAccountID,
+	}))
 
 	if roleEntry.TTL > 0 {
 		resp.Secret.TTL = roleEntry.TTL
@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/automatedrotationutil"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/rotation"
 )
 
 const (
@@ -21,6 +27,25 @@ const (
 	least with the ability to create an API and 
 	App key before using this secrets backend.
 	`
+	defaultSite = "datadoghq.com"
+)
+
+var (
+	// rootKeyScopes are the application key scopes the root
+	// credentials need in order to manage API and App keys
+	rootKeyScopes = []string{
+		"api_keys_write",
+		"user_app_keys",
+	}
+	datadogSites = []string{
+		"datadoghq.com",
+		"us3.datadoghq.com",
+		"us5.datadoghq.com",
+		"ap1.datadoghq.com",
+		"ap2.datadoghq.com",
+		"datadoghq.eu",
+		"ddog-gov.com",
+	}
 )
 
 type datadogConfig struct {
@@ -28,50 +53,172 @@ type datadogConfig struct {
 	APIKeyID string `json:"api_key_id"`
 	AppKey   string `json:"app_key"`
 	AppKeyID string `json:"app_key_id"`
+	Site     string `json:"site"`
+	APIURL   string `json:"api_url"`
+
+	RootAppKeyScopes    []string      `json:"root_app_key_scopes"`
+	RotationGracePeriod time.Duration `json:"rotation_grace_period"`
+
+	// MaxRetries is nil for configurations written before
+	// retries were supported
+	MaxRetries     *int          `json:"max_retries,omitempty"`
+	RequestTimeout time.Duration `json:"request_timeout"`
+
+	// MaxRequestsPerMinute limits key creation across all roles.
+	// RateLimitMaxWait is nil for configurations written before
+	// rate limiting was supported.
+	MaxRequestsPerMinute int            `json:"max_requests_per_minute"`
+	RateLimitMaxWait     *time.Duration `json:"rate_limit_max_wait,omitempty"`
+
+	MaxActiveKeys int `json:"max_active_keys"`
+
+	// KeyNamePrefix starts the names of all keys issued by
+	// the mount, and limits tidy to the keys of the mount
+	KeyNamePrefix string `json:"key_name_prefix"`
+
+	LastRotated time.Time `json:"last_rotated"`
+
+	automatedrotationutil.AutomatedRotationParams
 }
 
 func pathConfig(b *datadogBackend) *framework.Path {
 
-	return &framework.Path{
-		Pattern: pathConfigDef,
-		Fields: map[string]*framework.FieldSchema{
-			"api_key": {
-				Type:        framework.TypeString,
-				Description: "The API Key for accessing datadog's API",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "API Key",
-					Sensitive: true,
-				},
-			},
-			"api_key_id": {
-				Type:        framework.TypeString,
-				Description: "The ID of the datadog API Key",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "API Key ID",
-					Sensitive: false,
-				},
-			},
-			"app_key": {
-				Type:        framework.TypeString,
-				Description: "The Application Key scoped to admin level priveleges",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Application Key",
-					Sensitive: true,
-				},
-			},
-			"app_key_id": {
-				Type:        framework.TypeString,
-				Description: "The ID of the datadog Application Key",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Application Key ID",
-					Sensitive: false,
-				},
+	fields := map[string]*framework.FieldSchema{
+		"api_key": {
+			Type:        framework.TypeString,
+			Description: "The API Key for accessing datadog's API",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "API Key",
+				Sensitive: true,
+			},
+		},
+		"api_key_id": {
+			Type:        framework.TypeString,
+			Description: "The ID of the datadog API Key",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "API Key ID",
+				Sensitive: false,
+			},
+		},
+		"app_key": {
+			Type:        framework.TypeString,
+			Description: "The Application Key scoped to admin level priveleges",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Application Key",
+				Sensitive: true,
+			},
+		},
+		"app_key_id": {
+			Type:        framework.TypeString,
+			Description: "The ID of the datadog Application Key",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Application Key ID",
+				Sensitive: false,
+			},
+		},
+		"site": {
+			Type:        framework.TypeString,
+			Description: "The datadog site the organization is hosted on, e.g. datadoghq.eu or us5.datadoghq.com",
+			Default:     defaultSite,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Site",
+				Sensitive: false,
+			},
+		},
+		"api_url": {
+			Type:        framework.TypeString,
+			Description: "Optional. Base URL of the datadog API, e.g. https://api.datadoghq.com. Overrides site when set.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "API URL",
+				Sensitive: false,
+			},
+		},
+		"root_app_key_scopes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "Optional. Scopes applied to the root application key when it is rotated. If not set, the scopes of the current root application key are preserved.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Root Application Key Scopes",
+				Sensitive: false,
+			},
+		},
+		"rotation_grace_period": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Optional. Time to keep the replaced root keys after a rotation before they are deleted. If not set or set to 0, they are deleted immediately.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Rotation Grace Period",
+				Sensitive: false,
+			},
+		},
+		"max_retries": {
+			Type:        framework.TypeInt,
+			Description: "Optional. Number of times a datadog API request is retried when rate limited or failing. Defaults to 3.",
+			Default:     defaultMaxRetries,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Max Retries",
+				Sensitive: false,
+			},
+		},
+		"request_timeout": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Optional. Timeout of a single datadog API request. Defaults to 30s.",
+			Default:     int(defaultRequestTimeout.Seconds()),
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Request Timeout",
+				Sensitive: false,
+			},
+		},
+		"max_requests_per_minute": {
+			Type:        framework.TypeInt,
+			Description: "Optional. Maximum number of datadog keys created per minute across all roles. If not set or set to 0, key creation is not limited.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Max Requests Per Minute",
+				Sensitive: false,
+			},
+		},
+		"rate_limit_max_wait": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Optional. Longest time a rate limited request waits to create keys before it is rejected. Defaults to 10s.",
+			Default:     int(defaultRateLimitMaxWait.Seconds()),
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Rate Limit Max Wait",
+				Sensitive: false,
+			},
+		},
+		"max_active_keys": {
+			Type:        framework.TypeInt,
+			Description: "Optional. Maximum number of dynamic keys that may be active at once across all roles. If not set or set to 0, there is no limit.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Max Active Keys",
+				Sensitive: false,
+			},
+		},
+		"key_name_prefix": {
+			Type:        framework.TypeString,
+			Description: "Optional. Prefix added to the names of all keys issued by this mount. It must not start the names of keys issued by other mounts or created outside Vault. Required to tidy orphaned keys.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Key Name Prefix",
+				Sensitive: false,
 			},
 		},
+		"verify_connection": {
+			Type:        framework.TypeBool,
+			Description: "Optional. Verify the credentials against datadog before storing the configuration. Defaults to true.",
+			Default:     true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Verify Connection",
+				Sensitive: false,
+			},
+		},
+	}
+	automatedrotationutil.AddAutomatedRotationFields(fields)
+
+	return &framework.Path{
+		Pattern: pathConfigDef,
+		Fields:  fields,
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.CreateOperation: &framework.PathOperation{
 				Callback: b.pathConfigWrite,
@@ -99,11 +246,32 @@ func (b *datadogBackend) pathConfigRead(ctx context.Context, req *logical.Reques
 		return nil, err
 	}
 
+	if config == nil {
+		return nil, nil
+	}
+
+	respData := map[string]interface{}{
+		"api_key_id":              config.APIKeyID,
+		"app_key_id":              config.AppKeyID,
+		"site":                    config.getSite(),
+		"api_url":                 config.APIURL,
+		"root_app_key_scopes":     config.RootAppKeyScopes,
+		"rotation_grace_period":   config.RotationGracePeriod.Seconds(),
+		"max_retries":             config.getMaxRetries(),
+		"request_timeout":         config.getRequestTimeout().Seconds(),
+		"max_requests_per_minute": config.MaxRequestsPerMinute,
+		"rate_limit_max_wait":     config.getRateLimitMaxWait().Seconds(),
+		"max_active_keys":         config.MaxActiveKeys,
+		"key_name_prefix":         config.KeyNamePrefix,
+		"last_rotated":            "",
+	}
+	if !config.LastRotated.IsZero() {
+		respData["last_rotated"] = config.LastRotated.Format(time.RFC3339)
+	}
+	config.PopulateAutomatedRotationData(respData)
+
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"api_key_id": config.APIKeyID,
-			"app_key_id": config.AppKeyID,
-		},
+		Data: respData,
 	}, nil
 }
 
@@ -147,6 +315,139 @@ func (b *datadogBackend) pathConfigWrite(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("missing Application Key ID in configuration")
 	}
 
+	if site, ok := data.GetOk("site"); ok {
+		config.Site = site.(string)
+	} else if createOperation {
+		config.Site = data.Get("site").(string)
+	}
+
+	if !contains(datadogSites, config.getSite()) {
+		return logical.ErrorResponse("provided site %s is not a valid datadog site, must be one of %v", config.Site, datadogSites), nil
+	}
+
+	if apiURL, ok := data.GetOk("api_url"); ok {
+		config.APIURL = apiURL.(string)
+	}
+
+	if config.APIURL != "" {
+		if _, _, err := parseAPIURL(config.APIURL); err != nil {
+			return logical.ErrorResponse("invalid api_url: %s", err), nil
+		}
+	}
+
+	if scopes, ok := data.GetOk("root_app_key_scopes"); ok {
+		config.RootAppKeyScopes = scopes.([]string)
+	}
+
+	if gracePeriodRaw, ok := data.GetOk("rotation_grace_period"); ok {
+		config.RotationGracePeriod = time.Duration(gracePeriodRaw.(int)) * time.Second
+	}
+
+	if config.RotationGracePeriod < 0 {
+		return logical.ErrorResponse("rotation_grace_period cannot be negative"), nil
+	}
+
+	if maxRetriesRaw, ok := data.GetOk("max_retries"); ok {
+		maxRetries := maxRetriesRaw.(int)
+		config.MaxRetries = &maxRetries
+	} else if createOperation {
+		maxRetries := data.Get("max_retries").(int)
+		config.MaxRetries = &maxRetries
+	}
+
+	if config.getMaxRetries() < 0 {
+		return logical.ErrorResponse("max_retries cannot be negative"), nil
+	}
+
+	if requestTimeoutRaw, ok := data.GetOk("request_timeout"); ok {
+		config.RequestTimeout = time.Duration(requestTimeoutRaw.(int)) * time.Second
+		if config.RequestTimeout <= 0 {
+			return logical.ErrorResponse("request_timeout must be positive"), nil
+		}
+	} else if createOperation {
+		config.RequestTimeout = time.Duration(data.Get("request_timeout").(int)) * time.Second
+	}
+
+	if maxRequestsRaw, ok := data.GetOk("max_requests_per_minute"); ok {
+		config.MaxRequestsPerMinute = maxRequestsRaw.(int)
+	}
+
+	if config.MaxRequestsPerMinute < 0 {
+		return logical.ErrorResponse("max_requests_per_minute cannot be negative"), nil
+	}
+
+	if maxWaitRaw, ok := data.GetOk("rate_limit_max_wait"); ok {
+		maxWait := time.Duration(maxWaitRaw.(int)) * time.Second
+		config.RateLimitMaxWait = &maxWait
+	} else if createOperation {
+		maxWait := time.Duration(data.Get("rate_limit_max_wait").(int)) * time.Second
+		config.RateLimitMaxWait = &maxWait
+	}
+
+	if config.getRateLimitMaxWait() < 0 {
+		return logical.ErrorResponse("rate_limit_max_wait cannot be negative"), nil
+	}
+
+	if maxActiveKeysRaw, ok := data.GetOk("max_active_keys"); ok {
+		config.MaxActiveKeys = maxActiveKeysRaw.(int)
+	}
+
+	if config.MaxActiveKeys < 0 {
+		return logical.ErrorResponse("max_active_keys cannot be negative"), nil
+	}
+
+	if keyNamePrefix, ok := data.GetOk("key_name_prefix"); ok {
+		config.KeyNamePrefix = keyNamePrefix.(string)
+	}
+
+	if len(config.KeyNamePrefix) > maxKeyNamePrefixLength {
+		return logical.ErrorResponse("key_name_prefix cannot be longer than %d characters", maxKeyNamePrefixLength), nil
+	}
+
+	// an empty list leaves the rotated root application key unscoped
+	if len(config.RootAppKeyScopes) > 0 {
+		for _, scope := range rootKeyScopes {
+			if !contains(config.RootAppKeyScopes, scope) {
+				return logical.ErrorResponse("root_app_key_scopes must include the %s scope", scope), nil
+			}
+		}
+	}
+
+	// only deregister rotation jobs that were previously registered
+	rotationRegistered := config.HasNonzeroRotationValues() && !config.DisableAutomatedRotation
+
+	if err := config.ParseAutomatedRotationFields(data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if data.Get("verify_connection").(bool) {
+		if err := verifyConfig(ctx, config); err != nil {
+			return logical.ErrorResponse("error verifying datadog credentials: %s", err), nil
+		}
+	}
+
+	if config.ShouldDeregisterRotationJob() && rotationRegistered {
+		err := b.System().DeregisterRotationJob(ctx, &rotation.RotationJobDeregisterRequest{
+			MountPoint: req.MountPoint,
+			ReqPath:    req.Path,
+		})
+		if err != nil {
+			return logical.ErrorResponse("error deregistering root credential rotation: %s", err), nil
+		}
+	} else if config.ShouldRegisterRotationJob() {
+		_, err := b.System().RegisterRotationJob(ctx, &rotation.RotationJobConfigureRequest{
+			MountPoint:       req.MountPoint,
+			ReqPath:          req.Path,
+			RotationSchedule: config.RotationSchedule,
+			RotationWindow:   config.RotationWindow,
+			RotationPeriod:   config.RotationPeriod,
+			RotationPolicy:   config.RotationPolicy,
+		})
+		if err != nil {
+			return logical.ErrorResponse("error registering root credential rotation: %s", err), nil
+		}
+	}
+
 	entry, err := logical.StorageEntryJSON(configStoragePath, config)
 	if err != nil {
 		return nil, err
@@ -163,7 +464,22 @@ func (b *datadogBackend) pathConfigWrite(ctx context.Context, req *logical.Reque
 
 func (b *datadogBackend) pathConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 
-	err := req.Storage.Delete(ctx, configStoragePath)
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil && config.HasNonzeroRotationValues() && !config.DisableAutomatedRotation {
+		err := b.System().DeregisterRotationJob(ctx, &rotation.RotationJobDeregisterRequest{
+			MountPoint: req.MountPoint,
+			ReqPath:    req.Path,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error deregistering root credential rotation: %w", err)
+		}
+	}
+
+	err = req.Storage.Delete(ctx, configStoragePath)
 
 	if err == nil {
 		b.reset()
@@ -198,3 +514,115 @@ func getConfig(ctx context.Context, s logical.Storage) (*datadogConfig, error) {
 
 	return config, nil
 }
+
+// getMaxRetries returns the configured number of retries, falling back
+// to the default for configurations written before retries were supported
+func (c *datadogConfig) getMaxRetries() int {
+	if c.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+	return *c.MaxRetries
+}
+
+// getRequestTimeout returns the configured request timeout, falling back
+// to the default for configurations written before it was supported
+func (c *datadogConfig) getRequestTimeout() time.Duration {
+	if c.RequestTimeout <= 0 {
+		return defaultRequestTimeout
+	}
+	return c.RequestTimeout
+}
+
+// getRateLimitMaxWait returns the configured wait for rate limited
+// requests, falling back to the default for configurations written
+// before rate limiting was supported
+func (c *datadogConfig) getRateLimitMaxWait() time.Duration {
+	if c.RateLimitMaxWait == nil {
+		return defaultRateLimitMaxWait
+	}
+	return *c.RateLimitMaxWait
+}
+
+// getSite returns the configured datadog site, falling back to the
+// default US1 site for configurations written before site was supported
+func (c *datadogConfig) getSite() string {
+	if c.Site == "" {
+		return defaultSite
+	}
+	return c.Site
+}
+
+// parseAPIURL splits an API base URL into the protocol and name
+// server variables understood by the datadog API client
+func parseAPIURL(apiURL string) (string, string, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", "", errors.New("missing host")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", "", errors.New("query and fragment are not allowed")
+	}
+	return u.Scheme, u.Host + strings.TrimSuffix(u.Path, "/"), nil
+}
+
+// verifyConfig checks that datadog accepts the configured API and App
+// keys, that the key IDs belong to those keys and that the App key is
+// permitted to manage keys
+func verifyConfig(ctx context.Context, config *datadogConfig) error {
+
+	client, err := NewClient(config)
+	if err != nil {
+		return err
+	}
+
+	if err := client.validate(ctx); err != nil {
+		return fmt.Errorf("API key was rejected: %w", err)
+	}
+
+	apiKey, err := client.getAPIKey(ctx, config.APIKeyID)
+	if err != nil {
+		return describeVerifyError("API key", config.APIKeyID, err)
+	}
+	if !strings.HasSuffix(config.APIKey, apiKey.Last4) {
+		return fmt.Errorf("API key ID %s does not match the provided API key", config.APIKeyID)
+	}
+
+	appKey, err := client.getAppKey(ctx, config.AppKeyID)
+	if err != nil {
+		return describeVerifyError("application key", config.AppKeyID, err)
+	}
+	if !strings.HasSuffix(config.AppKey, appKey.Last4) {
+		return fmt.Errorf("application key ID %s does not match the provided application key", config.AppKeyID)
+	}
+
+	// an application key without scopes inherits every permission
+	// of its owner
+	if len(appKey.Scopes) > 0 {
+		for _, scope := range rootKeyScopes {
+			if !contains(appKey.Scopes, scope) {
+				return fmt.Errorf("application key is missing the %s scope", scope)
+			}
+		}
+	}
+
+	return nil
+}
+
+// describeVerifyError turns a failed key lookup into a message
+// explaining why the credentials were not accepted
+func describeVerifyError(keyType string, keyID string, err error) error {
+	switch statusCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("application key is invalid or lacks permission to read the %s: %w", keyType, err)
+	case http.StatusNotFound:
+		return fmt.Errorf("%s ID %s was not found: %w", keyType, keyID, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	pendingDeletionStoragePrefix     = "pending-deletions/"
+	pathPendingDeletionsHelpSynopsis = "List root keys queued for deletion"
+	pathPendingDeletionsHelpDesc     = `
+	Root keys replaced by a rotation are kept for the configured
+	rotation_grace_period before they are deleted. This path lists
+	the keys that are waiting to be deleted, and the keys kept by
+	a rotation with delete_old set to false, which are never
+	deleted by Vault.
+	`
+)
+
+// pendingDeletion is a replaced root key waiting for its
+// grace period to expire before it is deleted, or kept
+// indefinitely if it has no DeleteAfter
+type pendingDeletion struct {
+	KeyID       string    `json:"key_id"`
+	KeyType     string    `json:"key_type"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+func pathConfigPendingDeletions(b *datadogBackend) *framework.Path {
+
+	return &framework.Path{
+		Pattern: pathConfigDef + "/pending-deletions",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathPendingDeletionsRead,
+				Summary:  "List root keys queued for deletion",
+			},
+		},
+		HelpSynopsis:    pathPendingDeletionsHelpSynopsis,
+		HelpDescription: pathPendingDeletionsHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathPendingDeletionsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+
+	deletions, err := listPendingDeletions(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]map[string]interface{}, 0, len(deletions))
+	kept := make([]map[string]interface{}, 0)
+	for _, d := range deletions {
+		if d.DeleteAfter.IsZero() {
+			kept = append(kept, map[string]interface{}{
+				"key_id":   d.KeyID,
+				"key_type": d.KeyType,
+			})
+			continue
+		}
+		pending = append(pending, map[string]interface{}{
+			"key_id":       d.KeyID,
+			"key_type":     d.KeyType,
+			"delete_after": d.DeleteAfter.Format(time.RFC3339),
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"pending_deletions": pending,
+			"kept_keys":         kept,
+		},
+	}, nil
+}
+
+// queueRootKeyDeletions stores the old keys of a root rotation
+// to be deleted once deleteAfter has passed, or to be kept if
+// deleteAfter is zero
+func queueRootKeyDeletions(ctx context.Context, s logical.Storage, rotation *walRootRotation, deleteAfter time.Time) error {
+
+	if rotation.OldAPIKeyID != "" {
+		if err := putPendingDeletion(ctx, s, &pendingDeletion{
+			KeyID:       rotation.OldAPIKeyID,
+			KeyType:     credentialTypeAPIKey,
+			DeleteAfter: deleteAfter,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if rotation.OldAppKeyID != "" {
+		if err := putPendingDeletion(ctx, s, &pendingDeletion{
+			KeyID:       rotation.OldAppKeyID,
+			KeyType:     credentialTypeAppKey,
+			DeleteAfter: deleteAfter,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processPendingDeletions deletes queued root keys whose grace
+// period has expired
+func (b *datadogBackend) processPendingDeletions(ctx context.Context, s logical.Storage) error {
+
+	deletions, err := listPendingDeletions(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, d := range deletions {
+		if d.DeleteAfter.IsZero() || now.Before(d.DeleteAfter) {
+			continue
+		}
+
+		client, err := b.getClient(ctx, s)
+		if err != nil {
+			return fmt.Errorf("error getting client: %w", err)
+		}
+
+		switch d.KeyType {
+		case credentialTypeAPIKey:
+			err = deleteAPIKey(ctx, client, d.KeyID)
+		case credentialTypeAppKey:
+			err = deleteAppKey(ctx, client, d.KeyID)
+		default:
+			err = fmt.Errorf("unknown key type %q", d.KeyType)
+		}
+		if err != nil && statusCode(err) != http.StatusNotFound {
+			b.Logger().Warn("error deleting replaced root key", "key_id", d.KeyID, "key_type", d.KeyType, "error", err)
+			continue
+		}
+
+		if err := s.Delete(ctx, pendingDeletionStoragePath(d)); err != nil {
+			return err
+		}
+		b.Logger().Info("deleted replaced root key", "key_id", d.KeyID, "key_type", d.KeyType)
+	}
+
+	return nil
+}
+
+// listPendingDeletions returns the queued deletions ordered
+// by the time they become due
+func listPendingDeletions(ctx context.Context, s logical.Storage) ([]*pendingDeletion, error) {
+
+	keys, err := s.List(ctx, pendingDeletionStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	deletions := make([]*pendingDeletion, 0, len(keys))
+	for _, key := range keys {
+		entry, err := s.Get(ctx, pendingDeletionStoragePrefix+key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var d pendingDeletion
+		if err := entry.DecodeJSON(&d); err != nil {
+			return nil, fmt.Errorf("error reading pending deletion: %w", err)
+		}
+		deletions = append(deletions, &d)
+	}
+
+	sort.Slice(deletions, func(i, j int) bool {
+		return deletions[i].DeleteAfter.Before(deletions[j].DeleteAfter)
+	})
+
+	return deletions, nil
+}
+
+func putPendingDeletion(ctx context.Context, s logical.Storage, d *pendingDeletion) error {
+
+	entry, err := logical.StorageEntryJSON(pendingDeletionStoragePath(d), d)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func pendingDeletionStoragePath(d *pendingDeletion) string {
+	return pendingDeletionStoragePrefix + d.KeyType + "-" + d.KeyID
+}
@@ -2,7 +2,9 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/framework"
@@ -15,17 +17,53 @@ const (
 	`
 	pathConfigRotateHelpDesc = `
 	This will rotate the datadog API and App keys that are 
-	used to interact with the datadog platform.
+	used to interact with the datadog platform. Either key
+	can be rotated on its own, and the replaced keys can be
+	kept in datadog by setting delete_old to false, in which
+	case tidy never deletes them. If a rotation_grace_period
+	is configured, the replaced keys are deleted once it
+	expires.
 	`
+	rootKeyNamePrefix = "vault-config-"
+	rotateAPIKey      = "api"
+	rotateAppKey      = "app"
+	rotateBoth        = "both"
 )
 
+// rootRotationOptions controls which root keys are rotated
+// and what happens to the keys they replace
+type rootRotationOptions struct {
+	RotateAPIKey bool
+	RotateAppKey bool
+	NamePrefix   string
+	DeleteOld    bool
+}
+
 func pathConfigRotate(b *datadogBackend) *framework.Path {
 
 	return &framework.Path{
 		Pattern: pathConfigDef + "/rotate",
+		Fields: map[string]*framework.FieldSchema{
+			"rotate": {
+				Type:          framework.TypeString,
+				Description:   "Optional. Which root keys to rotate: api, app or both. Defaults to both.",
+				Default:       rotateBoth,
+				AllowedValues: []interface{}{rotateAPIKey, rotateAppKey, rotateBoth},
+			},
+			"name_prefix": {
+				Type:        framework.TypeString,
+				Description: "Optional. Prefix of the names given to the new keys in datadog.",
+				Default:     rootKeyNamePrefix,
+			},
+			"delete_old": {
+				Type:        framework.TypeBool,
+				Description: "Optional. Delete the replaced keys from datadog. Defaults to true.",
+				Default:     true,
+			},
+		},
 		Operations: map[logical.Operation]framework.OperationHandler{
-			logical.ReadOperation: &framework.PathOperation{
-				Callback: b.pathConfigRotateRead,
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigRotateWrite,
 				Summary:  "Rotate datadog API and App Keys",
 			},
 		},
@@ -34,7 +72,7 @@ func pathConfigRotate(b *datadogBackend) *framework.Path {
 	}
 }
 
-func (b *datadogBackend) pathConfigRotateRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+func (b *datadogBackend) pathConfigRotateWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 
 	config, err := getConfig(ctx, req.Storage)
 	if err != nil {
@@ -45,54 +83,205 @@ func (b *datadogBackend) pathConfigRotateRead(ctx context.Context, req *logical.
 		return logical.ErrorResponse("configuration not set"), nil
 	}
 
-	client, err := b.getClient(ctx, req.Storage)
+	opts := rootRotationOptions{
+		NamePrefix: data.Get("name_prefix").(string),
+		DeleteOld:  data.Get("delete_old").(bool),
+	}
+
+	switch rotate := data.Get("rotate").(string); rotate {
+	case rotateAPIKey:
+		opts.RotateAPIKey = true
+	case rotateAppKey:
+		opts.RotateAppKey = true
+	case rotateBoth:
+		opts.RotateAPIKey = true
+		opts.RotateAppKey = true
+	default:
+		return logical.ErrorResponse("invalid value %q for rotate, must be one of api, app or both", rotate), nil
+	}
+
+	config, rotation, err := b.rotateRootCredentials(ctx, req.Storage, opts)
 	if err != nil {
-		return nil, fmt.Errorf("error getting client: %w", err)
+		return nil, err
 	}
 
-	oldAPIKeyID := config.APIKeyID
-	oldAppKeyID := config.AppKeyID
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"api_key_id":       config.APIKeyID,
+			"app_key_id":       config.AppKeyID,
+			"old_api_key_id":   rotation.OldAPIKeyID,
+			"old_app_key_id":   rotation.OldAppKeyID,
+			"old_keys_deleted": !rotation.KeepOld && rotation.DeleteAfter.IsZero(),
+		},
+	}
+	if !rotation.DeleteAfter.IsZero() {
+		resp.Data["old_keys_delete_after"] = rotation.DeleteAfter.Format(time.RFC3339)
+	}
 
-	uuid, _ := uuid.GenerateUUID()
-	newAPIKey, err := createAPIKey(ctx, client, "vault-config-"+uuid)
+	return resp, nil
+}
+
+// rotateRootCredentials replaces the configured root API and/or App keys
+// with newly created ones, stores them and deletes the old keys unless
+// asked to keep them. Progress is tracked in a WAL entry so an interrupted
+// rotation is rolled back, or completed if the new keys were already
+// stored. Rotations are serialized, and the rotated config is returned.
+func (b *datadogBackend) rotateRootCredentials(ctx context.Context, s logical.Storage, opts rootRotationOptions) (*datadogConfig, *walRootRotation, error) {
+
+	b.rootRotationLock.Lock()
+	defer b.rootRotationLock.Unlock()
+
+	// the config may have been rotated while waiting for the lock
+	config, err := getConfig(ctx, s)
 	if err != nil {
-		return nil, fmt.Errorf("error rotating API key: %w", err)
+		return nil, nil, fmt.Errorf("error getting config: %w", err)
 	}
-	newAppKey, err := createAppKey(ctx, client, "vault-config-"+uuid, []string{})
+	if config == nil {
+		return nil, nil, errors.New("configuration not set")
+	}
+
+	client, err := b.getClient(ctx, s)
 	if err != nil {
-		return nil, fmt.Errorf("error rotating App key: %w", err)
+		return nil, nil, fmt.Errorf("error getting client: %w", err)
 	}
-	config.APIKey = newAPIKey.APIKey
-	config.AppKey = newAppKey.AppKey
-	config.APIKeyID = newAPIKey.APIKeyID
-	config.AppKeyID = newAppKey.AppKeyID
+
+	var scopes []string
+	if opts.RotateAppKey {
+		scopes, err = b.rootAppKeyScopes(ctx, client, config)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	wal := &walRootRotation{
+		KeepOld: !opts.DeleteOld,
+	}
+	if opts.DeleteOld && config.RotationGracePeriod > 0 {
+		wal.DeleteAfter = time.Now().UTC().Add(config.RotationGracePeriod)
+	}
+	if opts.RotateAPIKey {
+		wal.OldAPIKeyID = config.APIKeyID
+	}
+	if opts.RotateAppKey {
+		wal.OldAppKeyID = config.AppKeyID
+	}
+	walID, err := framework.PutWAL(ctx, s, walRootRotationKind, wal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	uuid, _ := uuid.GenerateUUID()
+	keyName := opts.NamePrefix + uuid
+
+	if opts.RotateAPIKey {
+		newAPIKey, err := createAPIKey(ctx, client, keyName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error rotating API key: %w", err)
+		}
+		wal.NewAPIKeyID = newAPIKey.APIKeyID
+		if walID, err = replaceWAL(ctx, s, walID, walRootRotationKind, wal); err != nil {
+			return nil, nil, fmt.Errorf("error writing WAL entry: %w", err)
+		}
+		config.APIKey = newAPIKey.APIKey
+		config.APIKeyID = newAPIKey.APIKeyID
+	}
+
+	if opts.RotateAppKey {
+		newAppKey, err := createAppKey(ctx, client, keyName, scopes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error rotating App key: %w", err)
+		}
+		wal.NewAppKeyID = newAppKey.AppKeyID
+		if walID, err = replaceWAL(ctx, s, walID, walRootRotationKind, wal); err != nil {
+			return nil, nil, fmt.Errorf("error writing WAL entry: %w", err)
+		}
+		config.AppKey = newAppKey.AppKey
+		config.AppKeyID = newAppKey.AppKeyID
+	}
+
+	config.LastRotated = time.Now().UTC()
 	entry, err := logical.StorageEntryJSON(configStoragePath, config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if err := req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
+	if err := s.Put(ctx, entry); err != nil {
+		return nil, nil, err
 	}
 
 	b.reset()
 
-	client, err = b.getClient(ctx, req.Storage)
-	if err != nil {
-		return nil, fmt.Errorf("error getting client: %w", err)
+	if !wal.DeleteAfter.IsZero() {
+		if err := queueRootKeyDeletions(ctx, s, wal, wal.DeleteAfter); err != nil {
+			return nil, nil, fmt.Errorf("error queueing old keys for deletion: %w", err)
+		}
+	} else if wal.KeepOld {
+		// kept keys are recorded so that tidy leaves them alone
+		if err := queueRootKeyDeletions(ctx, s, wal, time.Time{}); err != nil {
+			return nil, nil, fmt.Errorf("error recording kept keys: %w", err)
+		}
+	} else if opts.DeleteOld {
+		client, err = b.getClient(ctx, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting client: %w", err)
+		}
+
+		if wal.OldAPIKeyID != "" {
+			if err := deleteAPIKey(ctx, client, wal.OldAPIKeyID); err != nil {
+				return nil, nil, err
+			}
+		}
+		if wal.OldAppKeyID != "" {
+			if err := deleteAppKey(ctx, client, wal.OldAppKeyID); err != nil {
+				return nil, nil, err
+			}
+		}
 	}
 
-	err = deleteAPIKey(ctx, client, oldAPIKeyID)
-	if err != nil {
-		return nil, err
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return nil, nil, fmt.Errorf("error deleting WAL entry: %w", err)
+	}
+
+	return config, wal, nil
+}
+
+// rootAppKeyScopes returns the scopes for a rotated root application key,
+// either those configured explicitly or the scopes of the current key
+func (b *datadogBackend) rootAppKeyScopes(ctx context.Context, client *datadogClient, config *datadogConfig) ([]string, error) {
+
+	if len(config.RootAppKeyScopes) > 0 {
+		return config.RootAppKeyScopes, nil
 	}
-	err = deleteAppKey(ctx, client, oldAppKeyID)
+
+	appKey, err := client.getAppKey(ctx, config.AppKeyID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error reading scopes of the current App key: %w", err)
+	}
+
+	if appKey.Scopes == nil {
+		return []string{}, nil
+	}
+
+	return appKey.Scopes, nil
+}
+
+// rotateCredential is invoked by Vault's rotation manager to
+// rotate credentials registered for automated rotation
+func (b *datadogBackend) rotateCredential(ctx context.Context, req *logical.Request) error {
+
+	switch req.Path {
+	case pathConfigDef:
+		config, _, err := b.rotateRootCredentials(ctx, req.Storage, rootRotationOptions{
+			RotateAPIKey: true,
+			RotateAppKey: true,
+			NamePrefix:   rootKeyNamePrefix,
+			DeleteOld:    true,
+		})
+		if err != nil {
+			return err
+		}
+		b.Logger().Info("rotated root credentials", "api_key_id", config.APIKeyID, "app_key_id", config.AppKeyID)
+		return nil
+	default:
+		return fmt.Errorf("unknown rotation path %q", req.Path)
 	}
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"api_key_id": config.APIKeyID,
-			"app_key_id": config.AppKeyID,
-		},
-	}, nil
 }
@@ -0,0 +1,330 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRotate(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	t.Run("Manual Rotation", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathConfigDef + "/rotate",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotEqual(t, APIKeyID, resp.Data["api_key_id"])
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		require.False(t, config.LastRotated.IsZero())
+
+		apiKeys, appKeys := srv.keyCount()
+		require.Equal(t, 1, apiKeys)
+		require.Equal(t, 1, appKeys)
+
+		walKeys, err := framework.ListWAL(context.Background(), s)
+		require.NoError(t, err)
+		require.Empty(t, walKeys)
+	})
+
+	t.Run("Rotate App Key Only", func(t *testing.T) {
+		before, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathConfigDef + "/rotate",
+			Data: map[string]interface{}{
+				"rotate":      "app",
+				"name_prefix": "vault-test-",
+				"delete_old":  false,
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, before.APIKeyID, resp.Data["api_key_id"])
+		require.NotEqual(t, before.AppKeyID, resp.Data["app_key_id"])
+		require.Equal(t, "", resp.Data["old_api_key_id"])
+		require.Equal(t, before.AppKeyID, resp.Data["old_app_key_id"])
+		require.Equal(t, false, resp.Data["old_keys_deleted"])
+
+		require.Contains(t, srv.appKeys, before.AppKeyID)
+		require.Equal(t, "vault-test-", srv.appKeys[resp.Data["app_key_id"].(string)].Name[:len("vault-test-")])
+
+		// clean up the retained key so key counts stay accurate
+		delete(srv.appKeys, before.AppKeyID)
+	})
+
+	t.Run("Invalid Rotate Option", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathConfigDef + "/rotate",
+			Data:      map[string]interface{}{"rotate": "none"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Preserve Root App Key Scopes", func(t *testing.T) {
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		rootScopes := []string{"api_keys_write", "user_app_keys", "usage_read"}
+		srv.appKeys[config.AppKeyID].Scopes = rootScopes
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathConfigDef + "/rotate",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		config, err = getConfig(context.Background(), s)
+		require.NoError(t, err)
+		require.Equal(t, rootScopes, srv.appKeys[config.AppKeyID].Scopes)
+	})
+
+	t.Run("Configured Root App Key Scopes", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"root_app_key_scopes": "usage_read",
+		})
+		require.Error(t, err)
+
+		rootScopes := []string{"api_keys_write", "user_app_keys"}
+		err = testConfigUpdate(t, b, s, map[string]interface{}{
+			"root_app_key_scopes": rootScopes,
+		})
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathConfigDef + "/rotate",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		require.Equal(t, rootScopes, srv.appKeys[config.AppKeyID].Scopes)
+	})
+
+	t.Run("Register Rotation Job", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"rotation_period": "24h",
+		})
+		require.NoError(t, err)
+
+		sysView := b.System().(*testSystemView)
+		require.Contains(t, sysView.rotationJobs, pathConfigDef)
+		require.Equal(t, "24h0m0s", sysView.rotationJobs[pathConfigDef].RotationPeriod.String())
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      pathConfigDef,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, float64(86400), resp.Data["rotation_period"])
+		require.NotEmpty(t, resp.Data["last_rotated"])
+	})
+
+	t.Run("Automated Rotation", func(t *testing.T) {
+		before, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RotationOperation,
+			Path:      pathConfigDef,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		after, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		require.NotEqual(t, before.APIKeyID, after.APIKeyID)
+		require.NotEqual(t, before.AppKeyID, after.AppKeyID)
+	})
+
+	t.Run("Concurrent Rotations", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for _, op := range []logical.Operation{logical.UpdateOperation, logical.RotationOperation} {
+			path := pathConfigDef
+			if op == logical.UpdateOperation {
+				path += "/rotate"
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := b.HandleRequest(context.Background(), &logical.Request{
+					Operation: op,
+					Path:      path,
+					Storage:   s,
+				})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		// neither rotation leaves keys behind
+		apiKeys, appKeys := srv.keyCount()
+		require.Equal(t, 1, apiKeys)
+		require.Equal(t, 1, appKeys)
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		require.Contains(t, srv.apiKeys, config.APIKeyID)
+		require.Contains(t, srv.appKeys, config.AppKeyID)
+	})
+
+	t.Run("Deregister Rotation Job", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"disable_automated_rotation": true,
+		})
+		require.NoError(t, err)
+
+		sysView := b.System().(*testSystemView)
+		require.NotContains(t, sysView.rotationJobs, pathConfigDef)
+	})
+}
+
+func TestConfigRotateRollback(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	rollback := func(t *testing.T) {
+		t.Helper()
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Path:      "",
+			Data:      map[string]interface{}{"immediate": true},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		keys, err := framework.ListWAL(context.Background(), s)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	}
+
+	t.Run("Uncommitted Rotation", func(t *testing.T) {
+		newAPIKeyID := srv.addKey(srv.apiKeys, "vault-config-uncommitted")
+
+		_, err := framework.PutWAL(context.Background(), s, walRootRotationKind, &walRootRotation{
+			OldAPIKeyID: APIKeyID,
+			OldAppKeyID: AppKeyID,
+			NewAPIKeyID: newAPIKeyID,
+		})
+		require.NoError(t, err)
+
+		rollback(t)
+
+		require.NotContains(t, srv.apiKeys, newAPIKeyID)
+		require.Contains(t, srv.apiKeys, APIKeyID)
+		require.Contains(t, srv.appKeys, AppKeyID)
+	})
+
+	t.Run("Committed Rotation", func(t *testing.T) {
+		newAPIKeyID := srv.addKey(srv.apiKeys, "vault-config-committed")
+		newAppKeyID := srv.addKey(srv.appKeys, "vault-config-committed")
+
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"api_key":           srv.apiKeys[newAPIKeyID].Key,
+			"api_key_id":        newAPIKeyID,
+			"app_key":           srv.appKeys[newAppKeyID].Key,
+			"app_key_id":        newAppKeyID,
+			"verify_connection": false,
+		})
+		require.NoError(t, err)
+
+		_, err = framework.PutWAL(context.Background(), s, walRootRotationKind, &walRootRotation{
+			OldAPIKeyID: APIKeyID,
+			OldAppKeyID: AppKeyID,
+			NewAPIKeyID: newAPIKeyID,
+			NewAppKeyID: newAppKeyID,
+		})
+		require.NoError(t, err)
+
+		rollback(t)
+
+		require.NotContains(t, srv.apiKeys, APIKeyID)
+		require.NotContains(t, srv.appKeys, AppKeyID)
+		require.Contains(t, srv.apiKeys, newAPIKeyID)
+		require.Contains(t, srv.appKeys, newAppKeyID)
+	})
+}
+
+func TestConfigRotateGracePeriod(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	err := testConfigUpdate(t, b, s, map[string]interface{}{
+		"rotation_grace_period": "1h",
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      pathConfigDef + "/rotate",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.Equal(t, false, resp.Data["old_keys_deleted"])
+	require.NotEmpty(t, resp.Data["old_keys_delete_after"])
+
+	// the old keys are kept until the grace period expires
+	require.Contains(t, srv.apiKeys, APIKeyID)
+	require.Contains(t, srv.appKeys, AppKeyID)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      pathConfigDef + "/pending-deletions",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data["pending_deletions"], 2)
+
+	periodic := func() {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	}
+
+	periodic()
+	require.Contains(t, srv.apiKeys, APIKeyID)
+
+	// expire the grace period
+	deletions, err := listPendingDeletions(context.Background(), s)
+	require.NoError(t, err)
+	for _, d := range deletions {
+		d.DeleteAfter = time.Now().Add(-time.Minute)
+		require.NoError(t, putPendingDeletion(context.Background(), s, d))
+	}
+
+	periodic()
+	require.NotContains(t, srv.apiKeys, APIKeyID)
+	require.NotContains(t, srv.appKeys, AppKeyID)
+
+	deletions, err = listPendingDeletions(context.Background(), s)
+	require.NoError(t, err)
+	require.Empty(t, deletions)
+}
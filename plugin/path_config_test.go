@@ -26,20 +26,53 @@ func TestConfig(t *testing.T) {
 			"api_key_id": APIKeyID,
 			"app_key":    AppKey,
 			"app_key_id": AppKeyID,
+			// no datadog API is available to verify against
+			"verify_connection": false,
 		})
 		assert.NoError(t, err)
 
 		// test the config read functionality
 		err = testConfigRead(t, b, reqStorage, map[string]interface{}{
-			"api_key_id": "1e962ce6-b12a-4a87-bbb2-07fe5986334c",
-			"app_key_id": "1e962ce6-b12a-4a87-bbb2-07fe5986334c",
+			"api_key_id":                 "1e962ce6-b12a-4a87-bbb2-07fe5986334c",
+			"app_key_id":                 "1e962ce6-b12a-4a87-bbb2-07fe5986334c",
+			"site":                       "datadoghq.com",
+			"api_url":                    "",
+			"root_app_key_scopes":        nil,
+			"rotation_grace_period":      0,
+			"max_retries":                3,
+			"request_timeout":            30,
+			"max_requests_per_minute":    0,
+			"rate_limit_max_wait":        10,
+			"max_active_keys":            0,
+			"key_name_prefix":            "",
+			"last_rotated":               "",
+			"rotation_schedule":          "",
+			"rotation_window":            0,
+			"rotation_period":            0,
+			"disable_automated_rotation": false,
+			"rotation_policy":            "",
 		})
 		assert.NoError(t, err)
 
 		// test the config update functionality
 		err = testConfigUpdate(t, b, reqStorage, map[string]interface{}{
-			"api_key": APIKey,
-			"app_key": "r8fbb773f987b9b06cbced638d7dfc68cb3c7940",
+			"api_key":           APIKey,
+			"app_key":           "r8fbb773f987b9b06cbced638d7dfc68cb3c7940",
+			"verify_connection": false,
+		})
+		assert.NoError(t, err)
+
+		// test that an unknown site is rejected
+		err = testConfigUpdate(t, b, reqStorage, map[string]interface{}{
+			"site":              "datadoghq.invalid",
+			"verify_connection": false,
+		})
+		assert.Error(t, err)
+
+		// test that a valid non-US1 site is accepted
+		err = testConfigUpdate(t, b, reqStorage, map[string]interface{}{
+			"site":              "datadoghq.eu",
+			"verify_connection": false,
 		})
 		assert.NoError(t, err)
 
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	credsPath        = "creds/"
+	pathCredsHelpSyn = `
+	Generate a datadog API Key and Application Key from a role.
+	`
+	pathCredsHelpDesc = `
+	This path generates a datadog API Key and Application Key
+	based on a particular role. Both keys share a single lease
+	and are revoked together.
+	`
+)
+
+func pathCreds(b *datadogBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: credsPath + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCredsRead,
+			logical.UpdateOperation: b.pathCredsRead,
+		},
+		HelpSynopsis:    pathCredsHelpSyn,
+		HelpDescription: pathCredsHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathCredsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	if !roleEntry.allowsCredentialType(credentialTypeAPIKey) || !roleEntry.allowsCredentialType(credentialTypeAppKey) {
+		return logical.ErrorResponse("role %q is not permitted to issue both API and application keys", roleName), logical.ErrPermissionDenied
+	}
+
+	if roleEntry.Disabled {
+		return logical.ErrorResponse("role %q is disabled", roleName), logical.ErrPermissionDenied
+	}
+
+	if resp, err := b.limitKeyCreation(ctx, req.Storage, roleEntry, 2); resp != nil || err != nil {
+		return resp, err
+	}
+
+	release, limitResp, err := b.limitActiveKeys(ctx, req.Storage, roleEntry, 2)
+	if limitResp != nil || err != nil {
+		return limitResp, err
+	}
+	defer release()
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	keyName, err := generateKeyName(ctx, req, roleEntry)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	apiKey, err := createAPIKey(ctx, client, keyName)
+	if err != nil {
+		return nil, fmt.Errorf("error creating datadog API key: %w", err)
+	}
+
+	appKey, err := createRoleAppKey(ctx, client, roleEntry, keyName)
+	if err != nil {
+		// don't leave an API key behind that no lease will revoke
+		if rollbackErr := deleteAPIKey(ctx, client, apiKey.APIKeyID); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog API key", "api_key_id", apiKey.APIKeyID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error creating datadog application key: %w", err)
+	}
+
+	issuedAPIKey := b.newIssuedKey(req, roleEntry, credentialTypeAPIKey, apiKey.APIKeyID)
+	issuedAppKey := b.newIssuedKey(req, roleEntry, credentialTypeAppKey, appKey.AppKeyID)
+	recorded := make([]*issuedKey, 0, 2)
+	for _, issued := range []*issuedKey{issuedAPIKey, issuedAppKey} {
+		if err := b.putIssuedKey(ctx, req.Storage, issued); err != nil {
+			for _, r := range recorded {
+				if rollbackErr := b.deleteIssuedKey(ctx, req.Storage, r.KeyID); rollbackErr != nil {
+					b.Logger().Error("error rolling back issued key record", "key_id", r.KeyID, "error", rollbackErr)
+				}
+			}
+			if rollbackErr := deleteRoleAppKey(ctx, client, roleEntry.ServiceAccountID, appKey.AppKeyID); rollbackErr != nil {
+				b.Logger().Error("error rolling back datadog application key", "app_key_id", appKey.AppKeyID, "error", rollbackErr)
+			}
+			if rollbackErr := deleteAPIKey(ctx, client, apiKey.APIKeyID); rollbackErr != nil {
+				b.Logger().Error("error rolling back datadog API key", "api_key_id", apiKey.APIKeyID, "error", rollbackErr)
+			}
+			return nil, fmt.Errorf("error recording issued keys: %w", err)
+		}
+		recorded = append(recorded, issued)
+	}
+
+	resp := b.Secret(datadogCredsType).Response(map[string]interface{}{
+		"api_key": apiKey.APIKey,
+		"app_key": appKey.AppKey,
+	}, issuedAPIKey.internalData(map[string]interface{}{
+		"api_key_id":         apiKey.APIKeyID,
+		"app_key_id":         appKey.AppKeyID,
+		"role":               roleEntry.Name,
+		"service_account_id": roleEntry.ServiceAccountID,
+	}))
+
+	if roleEntry.TTL > 0 {
+		resp.Secret.TTL = roleEntry.TTL
+	}
+
+	if roleEntry.MaxTTL > 0 {
+		resp.Secret.MaxTTL = roleEntry.MaxTTL
+	}
+
+	return resp, nil
+}
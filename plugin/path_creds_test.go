@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreds(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	t.Run("Issue Both Keys", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      credsPath + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.Data["api_key"])
+		require.NotEmpty(t, resp.Data["app_key"])
+
+		apiKeys, appKeys := srv.keyCount()
+		require.Equal(t, 1, apiKeys)
+		require.Equal(t, 1, appKeys)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		apiKeys, appKeys = srv.keyCount()
+		require.Zero(t, apiKeys)
+		require.Zero(t, appKeys)
+	})
+
+	t.Run("Roll Back API Key", func(t *testing.T) {
+		srv.failAppKeyCreate = true
+		defer func() { srv.failAppKeyCreate = false }()
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      credsPath + roleName,
+			Storage:   s,
+		})
+		require.Error(t, err)
+
+		apiKeys, _ := srv.keyCount()
+		require.Zero(t, apiKeys)
+	})
+
+	t.Run("Roll Back Issued Keys", func(t *testing.T) {
+		failing := &failingPutStorage{Storage: s, prefix: issuedKeyStoragePrefix, failAfter: 1}
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      credsPath + roleName,
+			Storage:   failing,
+		})
+		require.Error(t, err)
+
+		apiKeys, appKeys := srv.keyCount()
+		require.Zero(t, apiKeys)
+		require.Zero(t, appKeys)
+
+		keys, err := listIssuedKeys(context.Background(), s)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("Missing Role", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      credsPath + "missing",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+// failingPutStorage fails the writes under prefix once failAfter
+// of them have succeeded
+type failingPutStorage struct {
+	logical.Storage
+	prefix    string
+	failAfter int
+	puts      int
+}
+
+func (s *failingPutStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if strings.HasPrefix(entry.Key, s.prefix) {
+		if s.puts >= s.failAfter {
+			return errors.New("storage unavailable")
+		}
+		s.puts++
+	}
+	return s.Storage.Put(ctx, entry)
+}
@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	elevationPath        = "elevation/"
+	pathElevationHelpSyn = `
+	Add a datadog role to the datadog user of the requesting Vault entity.
+	`
+	pathElevationHelpDesc = `
+	This path adds the datadog role of a particular role to the existing
+	datadog user of the requesting Vault entity, found by the email in
+	the metadata or the aliases of the entity. The user must not already
+	hold the datadog role. When the lease ends, the datadog role is
+	removed from the user again.
+	`
+)
+
+func pathElevation(b *datadogBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: elevationPath + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathElevationRead,
+			logical.UpdateOperation: b.pathElevationRead,
+		},
+		HelpSynopsis:    pathElevationHelpSyn,
+		HelpDescription: pathElevationHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathElevationRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	if !roleEntry.allowsCredentialType(credentialTypeElevation) {
+		return logical.ErrorResponse("role %q is not permitted to issue elevations", roleName), logical.ErrPermissionDenied
+	}
+
+	if roleEntry.Disabled {
+		return logical.ErrorResponse("role %q is disabled", roleName), logical.ErrPermissionDenied
+	}
+
+	requester, errResp, err := b.getRequester(req, roleEntry)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	if resp, err := b.limitKeyCreation(ctx, req.Storage, roleEntry, 1); resp != nil || err != nil {
+		return resp, err
+	}
+
+	release, limitResp, err := b.limitActiveKeys(ctx, req.Storage, roleEntry, 1)
+	if limitResp != nil || err != nil {
+		return limitResp, err
+	}
+	defer release()
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	user, err := client.getUserByEmail(ctx, requester.Email)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up datadog user: %w", err)
+	}
+
+	if user == nil {
+		return logical.ErrorResponse("no datadog user has the email %q", requester.Email), nil
+	}
+
+	if user.Disabled {
+		return logical.ErrorResponse("datadog user %q is disabled", requester.Email), nil
+	}
+
+	id := elevationID(user.ID, roleEntry.DatadogRoleID)
+
+	lock := locksutil.LockForKey(b.issueLocks, id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// an elevation of a revoke-all keeps its record until its
+	// lease ends, and the role must not be added again before
+	// then, unlike one whose revocation failed after its lease ended
+	outstanding, err := getIssuedKey(ctx, req.Storage, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading issued elevation: %w", err)
+	}
+	if outstanding != nil && outstanding.RevocationError == "" {
+		return logical.ErrorResponse("datadog user %q was already elevated to datadog role %q and its lease has not ended", requester.Email, roleEntry.DatadogRoleID), nil
+	}
+
+	// removing a role the user held before the elevation
+	// would not restore its original membership
+	if contains(user.RoleIDs, roleEntry.DatadogRoleID) {
+		return logical.ErrorResponse("datadog user %q already holds datadog role %q", requester.Email, roleEntry.DatadogRoleID), nil
+	}
+
+	if err := client.addUserToRole(ctx, roleEntry.DatadogRoleID, user.ID); err != nil {
+		return nil, fmt.Errorf("error elevating datadog user: %w", err)
+	}
+
+	issued := b.newIssuedKey(req, roleEntry, credentialTypeElevation, id)
+	if err := b.putIssuedKey(ctx, req.Storage, issued); err != nil {
+		if rollbackErr := deleteElevation(ctx, client, id); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog elevation", "user_id", user.ID, "datadog_role_id", roleEntry.DatadogRoleID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error recording issued elevation: %w", err)
+	}
+
+	resp := b.Secret(datadogElevationType).Response(map[string]interface{}{
+		"user_id":         user.ID,
+		"email":           user.Email,
+		"datadog_role_id": roleEntry.DatadogRoleID,
+	}, issued.internalData(map[string]interface{}{
+		"elevation_id": id,
+		"role":         roleEntry.Name,
+	}))
+
+	if roleEntry.TTL > 0 {
+		resp.Secret.TTL = roleEntry.TTL
+	}
+
+	if roleEntry.MaxTTL > 0 {
+		resp.Secret.MaxTTL = roleEntry.MaxTTL
+	}
+
+	return resp, nil
+}
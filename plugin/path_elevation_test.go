@@ -0,0 +1,143 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElevation(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	const datadogRoleID = "role-admin"
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"credential_types": []string{credentialTypeElevation},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"credential_types": []string{credentialTypeElevation},
+		"datadog_role_id":  datadogRoleID,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	srv.users["alice"] = &testDatadogUser{
+		Email: "alice@example.com",
+		Roles: []string{"role-read-only"},
+	}
+
+	// the email comes from an alias as the entity has no metadata
+	sys := b.System().(*testSystemView)
+	setEntity := func(email string) {
+		sys.EntityVal = &logical.Entity{
+			ID:      "test-entity",
+			Name:    "alice",
+			Aliases: []*logical.Alias{{Name: "alice"}, {Name: email}},
+		}
+	}
+	setEntity("alice@example.com")
+
+	elevate := func(t *testing.T) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      elevationPath + roleName,
+			Storage:   s,
+			EntityID:  "test-entity",
+		})
+	}
+
+	t.Run("Elevate And Revoke", func(t *testing.T) {
+		resp, err := elevate(t)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, "alice", resp.Data["user_id"])
+		require.Equal(t, datadogRoleID, resp.Data["datadog_role_id"])
+		require.Equal(t, []string{"role-read-only", datadogRoleID}, srv.users["alice"].Roles)
+
+		dupResp, err := elevate(t)
+		require.NoError(t, err)
+		require.True(t, dupResp.IsError())
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"role-read-only"}, srv.users["alice"].Roles)
+
+		keys, err := listIssuedKeys(context.Background(), s)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("Concurrent Elevations", func(t *testing.T) {
+		var wg sync.WaitGroup
+		resps := make(chan *logical.Response, 2)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := elevate(t)
+				require.NoError(t, err)
+				resps <- resp
+			}()
+		}
+		wg.Wait()
+		close(resps)
+
+		// only one of the requests adds the role
+		var issued *logical.Response
+		for resp := range resps {
+			if !resp.IsError() {
+				require.Nil(t, issued)
+				issued = resp
+			}
+		}
+		require.NotNil(t, issued)
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    issued.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"role-read-only"}, srv.users["alice"].Roles)
+	})
+
+	t.Run("Already Holds Role", func(t *testing.T) {
+		srv.users["alice"].Roles = []string{datadogRoleID}
+		defer func() { srv.users["alice"].Roles = []string{"role-read-only"} }()
+
+		resp, err := elevate(t)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Equal(t, []string{datadogRoleID}, srv.users["alice"].Roles)
+	})
+
+	t.Run("Disabled User", func(t *testing.T) {
+		srv.users["alice"].Disabled = true
+		defer func() { srv.users["alice"].Disabled = false }()
+
+		resp, err := elevate(t)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		setEntity("bob@example.com")
+		defer setEntity("alice@example.com")
+
+		resp, err := elevate(t)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
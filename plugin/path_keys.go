@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	keysPath              = "keys/"
+	pathKeysLookupHelpSyn = `
+	Look up the requester of an issued datadog key.
+	`
+	pathKeysLookupHelpDesc = `
+	This path returns the role, requesting Vault identity, issue
+	time and lease expiry of a dynamic datadog API or Application
+	Key, given its datadog key ID.
+	`
+	pathKeysListHelpSyn = `
+	List the dynamic datadog keys that are currently issued.
+	`
+	pathKeysListHelpDesc = `
+	This path lists the IDs of the dynamic datadog API and Application
+	Keys that have been issued and not yet revoked, along with their
+	type, role, lease ID and issue time. The lease ID of a key is
+	recorded the first time its lease is renewed.
+	`
+	pathRoleKeysListHelpSyn = `
+	List the dynamic datadog keys that are currently issued from a role.
+	`
+)
+
+func pathKeys(b *datadogBackend) []*framework.Path {
+	return []*framework.Path{
+		pathKeysLookup(b),
+		{
+			Pattern: keysPath + "?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.pathKeysList,
+			},
+			HelpSynopsis:    pathKeysListHelpSyn,
+			HelpDescription: pathKeysListHelpDesc,
+		},
+		{
+			Pattern: pathRoleDef + framework.GenericNameRegex("name") + "/keys/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the role",
+					Required:    true,
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.pathRoleKeysList,
+			},
+			HelpSynopsis:    pathRoleKeysListHelpSyn,
+			HelpDescription: pathKeysListHelpDesc,
+		},
+	}
+}
+
+func pathKeysLookup(b *datadogBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: keysPath + "lookup",
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the datadog API or Application Key",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeysLookup,
+			logical.UpdateOperation: b.pathKeysLookup,
+		},
+		HelpSynopsis:    pathKeysLookupHelpSyn,
+		HelpDescription: pathKeysLookupHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathKeysLookup(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	keyID := d.Get("key_id").(string)
+	if keyID == "" {
+		return logical.ErrorResponse("missing key_id"), nil
+	}
+
+	k, err := getIssuedKey(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving issued key: %w", err)
+	}
+
+	if k == nil {
+		return logical.ErrorResponse("key %q was not issued by this backend", keyID), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":           k.KeyID,
+			"key_type":         k.KeyType,
+			"role":             k.Role,
+			"entity_id":        k.EntityID,
+			"display_name":     k.DisplayName,
+			"mount_accessor":   k.MountAccessor,
+			"lease_id":         k.LeaseID,
+			"revoked":          k.Revoked,
+			"revocation_error": k.RevocationError,
+			"issue_time":       k.IssueTime.Format(time.RFC3339),
+			"expire_time":      k.ExpireTime.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+func (b *datadogBackend) pathKeysList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	keys, err := listIssuedKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	return issuedKeysListResponse(keys), nil
+}
+
+func (b *datadogBackend) pathRoleKeysList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+
+	keys, err := listIssuedKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	roleKeys := make([]*issuedKey, 0, len(keys))
+	for _, k := range keys {
+		if k.Role == roleName {
+			roleKeys = append(roleKeys, k)
+		}
+	}
+
+	return issuedKeysListResponse(roleKeys), nil
+}
+
+// issuedKeysListResponse lists the IDs of issued keys
+// with their details as key info
+func issuedKeysListResponse(keys []*issuedKey) *logical.Response {
+
+	keyIDs := make([]string, 0, len(keys))
+	keyInfo := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		// keys deleted by a revoke-all are no longer live
+		if k.Revoked {
+			continue
+		}
+		keyIDs = append(keyIDs, k.KeyID)
+		info := map[string]interface{}{
+			"key_type":   k.KeyType,
+			"role":       k.Role,
+			"lease_id":   k.LeaseID,
+			"issue_time": k.IssueTime.Format(time.RFC3339),
+		}
+		if k.RevocationError != "" {
+			info["revocation_error"] = k.RevocationError
+		}
+		keyInfo[k.KeyID] = info
+	}
+
+	return logical.ListResponseWithInfo(keyIDs, keyInfo)
+}
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeysLookup(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:     logical.ReadOperation,
+		Path:          apiKeyPath + roleName,
+		Storage:       s,
+		EntityID:      "test-entity",
+		DisplayName:   "token-test",
+		MountAccessor: "datadog_1234",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+	require.Equal(t, "test-entity", resp.Secret.InternalData["entity_id"])
+	require.Equal(t, "token-test", resp.Secret.InternalData["display_name"])
+	require.Equal(t, "datadog_1234", resp.Secret.InternalData["mount_accessor"])
+	require.NotEmpty(t, resp.Secret.InternalData["issue_time"])
+
+	keyID := resp.Secret.InternalData["api_key_id"].(string)
+
+	t.Run("Lookup", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      keysPath + "lookup",
+			Data:      map[string]interface{}{"key_id": keyID},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, keyID, resp.Data["key_id"])
+		require.Equal(t, credentialTypeAPIKey, resp.Data["key_type"])
+		require.Equal(t, roleName, resp.Data["role"])
+		require.Equal(t, "test-entity", resp.Data["entity_id"])
+		require.Equal(t, "token-test", resp.Data["display_name"])
+		require.Equal(t, "datadog_1234", resp.Data["mount_accessor"])
+		require.NotEmpty(t, resp.Data["expire_time"])
+	})
+
+	t.Run("Unknown Key", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      keysPath + "lookup",
+			Data:      map[string]interface{}{"key_id": "unknown"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Revoked Key", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      keysPath + "lookup",
+			Data:      map[string]interface{}{"key_id": keyID},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestKeysList(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	for _, name := range []string{roleName, "other"} {
+		_, err := testTokenRoleCreate(t, b, s, name, map[string]interface{}{
+			"app_key_scopes": scopes,
+		})
+		require.NoError(t, err)
+	}
+
+	var secrets []*logical.Secret
+	for _, path := range []string{apiKeyPath + roleName, credsPath + roleName, appKeyPath + "other"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		secrets = append(secrets, resp.Secret)
+	}
+
+	list := func(t *testing.T, path string) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      path,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		return resp
+	}
+
+	t.Run("All Keys", func(t *testing.T) {
+		resp := list(t, keysPath)
+		require.Len(t, resp.Data["keys"], 4)
+	})
+
+	t.Run("Role Keys", func(t *testing.T) {
+		resp := list(t, pathRoleDef+roleName+"/keys")
+		require.Len(t, resp.Data["keys"], 3)
+
+		apiKeyID := secrets[0].InternalData["api_key_id"].(string)
+		info := resp.Data["key_info"].(map[string]interface{})[apiKeyID].(map[string]interface{})
+		require.Equal(t, credentialTypeAPIKey, info["key_type"])
+		require.Equal(t, roleName, info["role"])
+		require.NotEmpty(t, info["issue_time"])
+
+		resp = list(t, pathRoleDef+"other/keys")
+		require.Len(t, resp.Data["keys"], 1)
+	})
+
+	t.Run("Lease ID", func(t *testing.T) {
+		secret := *secrets[0]
+		secret.LeaseID = "datadog/apikey/" + roleName + "/abcd"
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    &secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		apiKeyID := secrets[0].InternalData["api_key_id"].(string)
+		resp := list(t, keysPath)
+		info := resp.Data["key_info"].(map[string]interface{})[apiKeyID].(map[string]interface{})
+		require.Equal(t, secret.LeaseID, info["lease_id"])
+	})
+
+	t.Run("Revoked Keys", func(t *testing.T) {
+		for _, secret := range secrets {
+			_, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.RevokeOperation,
+				Secret:    secret,
+				Storage:   s,
+			})
+			require.NoError(t, err)
+		}
+
+		resp := list(t, keysPath)
+		require.Empty(t, resp.Data["keys"])
+	})
+}
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	rateLimitsPath                = "rate-limits"
+	pathRateLimitsHelpSynopsis    = "Show the state of the key creation rate limits"
+	pathRateLimitsHelpDescription = `
+	This path returns the limit and the number of keys that can be
+	created right away for the max_requests_per_minute of the config
+	and of every role which has issued keys under a limit.
+	`
+)
+
+func pathRateLimits(b *datadogBackend) *framework.Path {
+
+	return &framework.Path{
+		Pattern: rateLimitsPath + "$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRateLimitsRead,
+				Summary:  "Show the state of the key creation rate limits",
+			},
+		},
+		HelpSynopsis:    pathRateLimitsHelpSynopsis,
+		HelpDescription: pathRateLimitsHelpDescription,
+	}
+}
+
+func (b *datadogBackend) pathRateLimitsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+
+	return &logical.Response{
+		Data: b.limiter.status(),
+	}, nil
+}
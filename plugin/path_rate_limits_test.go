@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimits(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	issue := func(path string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Storage:   s,
+		})
+	}
+
+	status := func(t *testing.T) map[string]interface{} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      rateLimitsPath,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		return resp.Data
+	}
+
+	t.Run("Global", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"max_requests_per_minute": 2,
+			"rate_limit_max_wait":     0,
+		})
+		require.NoError(t, err)
+
+		_, err = testTokenRoleCreate(t, b, s, "global", map[string]interface{}{})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := issue(apiKeyPath + "global")
+			require.NoError(t, err)
+		}
+
+		resp, err := issue(apiKeyPath + "global")
+		require.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+		require.True(t, resp.IsError())
+
+		global := status(t)["global"].(map[string]interface{})
+		require.Equal(t, 2, global["max_requests_per_minute"])
+		require.Equal(t, 0, global["available"])
+
+		err = testConfigUpdate(t, b, s, map[string]interface{}{
+			"max_requests_per_minute": 0,
+		})
+		require.NoError(t, err)
+
+		_, err = issue(apiKeyPath + "global")
+		require.NoError(t, err)
+		require.Nil(t, status(t)["global"])
+	})
+
+	t.Run("Role", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, "limited", map[string]interface{}{
+			"max_requests_per_minute": 1,
+		})
+		require.NoError(t, err)
+
+		// creds need two keys, which the role can never create at once
+		_, err = issue(credsPath + "limited")
+		require.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+
+		_, err = issue(appKeyPath + "limited")
+		require.NoError(t, err)
+
+		_, err = issue(appKeyPath + "limited")
+		require.ErrorIs(t, err, logical.ErrRateLimitQuotaExceeded)
+
+		roles := status(t)["roles"].(map[string]interface{})
+		require.Contains(t, roles, "limited")
+	})
+
+	t.Run("Queued", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"rate_limit_max_wait": 5,
+		})
+		require.NoError(t, err)
+
+		_, err = testTokenRoleCreate(t, b, s, "queued", map[string]interface{}{
+			"max_requests_per_minute": 120,
+		})
+		require.NoError(t, err)
+
+		_, err = issue(apiKeyPath + "queued")
+		require.NoError(t, err)
+
+		// empty the bucket, so the next key waits for it to
+		// refill instead of being refused
+		l := roleLimiter(b.limiter, "queued")
+		require.NotNil(t, l)
+		now := time.Now()
+		require.True(t, l.AllowN(now, int(l.TokensAt(now))))
+
+		r := l.Reserve()
+		delay := r.Delay()
+		r.Cancel()
+		require.Positive(t, delay)
+		require.Less(t, delay, 5*time.Second)
+
+		_, err = issue(apiKeyPath + "queued")
+		require.NoError(t, err)
+		require.Less(t, l.Tokens(), 1.0)
+	})
+}
+
+// roleLimiter returns the limiter of a role, if it has been used
+func roleLimiter(l *keyCreationLimiter, name string) *requestLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.roles[name]
+}
@@ -53,14 +53,55 @@ var (
 	}
 )
 
+const (
+	credentialTypeAPIKey = "api_key"
+	credentialTypeAppKey = "app_key"
+	credentialTypeBoth   = "both"
+
+	// credentialTypeServiceAccount issues a datadog service
+	// account per lease, along with an application key it owns
+	credentialTypeServiceAccount = "service_account"
+
+	// credentialTypeUser issues a datadog user per lease
+	// to the requesting Vault entity
+	credentialTypeUser = "user"
+
+	// credentialTypeElevation adds a datadog role to the existing
+	// datadog user of the requesting Vault entity for a lease
+	credentialTypeElevation = "elevation"
+
+	// defaultEmailMetadataKey is the entity metadata key
+	// holding the email of the datadog users of an entity
+	defaultEmailMetadataKey = "email"
+)
+
+var (
+	// defaultCredentialTypes are permitted for roles which
+	// don't restrict their credential types
+	defaultCredentialTypes = []string{
+		credentialTypeAPIKey,
+		credentialTypeAppKey,
+	}
+)
+
 // datadogRoleEntry defines the data associated with
 // a Vault role for interoperating with the datadog
 // api
 type datadogRoleEntry struct {
-	Name         string        `json:"name"`
-	AppKeyScopes []string      `json:"app_key_scopes"`
-	TTL          time.Duration `json:"ttl"`
-	MaxTTL       time.Duration `json:"max_ttl"`
+	Name                 string        `json:"name"`
+	AppKeyScopes         []string      `json:"app_key_scopes"`
+	CredentialTypes      []string      `json:"credential_types"`
+	NameTemplate         string        `json:"name_template"`
+	Disabled             bool          `json:"disabled"`
+	MaxRequestsPerMinute int           `json:"max_requests_per_minute"`
+	MaxActiveKeys        int           `json:"max_active_keys"`
+	ServiceAccountID     string        `json:"service_account_id"`
+	DatadogRoleIDs       []string      `json:"datadog_role_ids"`
+	EmailTemplate        string        `json:"email_template"`
+	EmailMetadataKey     string        `json:"email_metadata_key"`
+	DatadogRoleID        string        `json:"datadog_role_id"`
+	TTL                  time.Duration `json:"ttl"`
+	MaxTTL               time.Duration `json:"max_ttl"`
 }
 
 // pathRole defines the framework.Path for datadog roles
@@ -79,6 +120,47 @@ func pathRole(b *datadogBackend) []*framework.Path {
 					Type:        framework.TypeCommaStringSlice,
 					Description: "Optional. List of datadog permissions scopes to be applied to the application key.",
 				},
+				"credential_types": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Optional. Types of credentials the role may issue: api_key, app_key, both, service_account, user or elevation. Defaults to both.",
+					Default:     []string{credentialTypeBoth},
+				},
+				"name_template": {
+					Type:        framework.TypeString,
+					Description: "Optional. Template for the names of generated keys, which follow the key_name_prefix of the config. Available data: .RoleName, .DisplayName, .EntityID, .MountPath. Defaults to {{ .RoleName }}-{{ uuid }}.",
+				},
+				"disabled": {
+					Type:        framework.TypeBool,
+					Description: "Optional. Prevent the role from issuing credentials. Set by revoke-all when disable_role is true.",
+				},
+				"service_account_id": {
+					Type:        framework.TypeString,
+					Description: "Optional. ID of the datadog service account that owns the application keys of the role. If not set, they are owned by the owner of the root application key.",
+				},
+				"datadog_role_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Optional. IDs of the datadog roles of the service accounts and users issued from the role. Required to issue service accounts or users.",
+				},
+				"email_template": {
+					Type:        framework.TypeString,
+					Description: "Optional. Template for the emails of the service accounts issued from the role. Available data: .RoleName, .DisplayName, .EntityID, .MountPath. Required to issue service accounts.",
+				},
+				"email_metadata_key": {
+					Type:        framework.TypeString,
+					Description: "Optional. Metadata key of the Vault entity holding the email of its datadog user, for users and elevations issued from the role. If the metadata is missing, the first alias of the entity named with an email is used. Defaults to email.",
+				},
+				"datadog_role_id": {
+					Type:        framework.TypeString,
+					Description: "Optional. ID of the datadog role added to the user of the requesting entity by elevations issued from the role. Required to issue elevations.",
+				},
+				"max_requests_per_minute": {
+					Type:        framework.TypeInt,
+					Description: "Optional. Maximum number of datadog keys the role creates per minute. If not set or set to 0, only the limit of the config applies.",
+				},
+				"max_active_keys": {
+					Type:        framework.TypeInt,
+					Description: "Optional. Maximum number of keys issued from the role that may be active at once. If not set or set to 0, there is no limit.",
+				},
 				"ttl": {
 					Type:        framework.TypeDurationSecond,
 					Description: "Optional. Default lease time for generated credentials. If not set or set to 0, system default will be used.",
@@ -159,7 +241,10 @@ func (b *datadogBackend) pathRolesWrite(ctx context.Context, req *logical.Reques
 	if err != nil {
 		return nil, err
 	}
-	if roleEntry == nil {
+	// defaults only apply to new roles, whatever
+	// operation Vault routes the write as
+	newRole := roleEntry == nil
+	if newRole {
 		roleEntry = &datadogRoleEntry{}
 	}
 
@@ -187,6 +272,81 @@ func (b *datadogBackend) pathRolesWrite(ctx context.Context, req *logical.Reques
 		}
 	}
 
+	if credentialTypesRaw, ok := d.GetOk("credential_types"); ok {
+		credentialTypes, err := parseCredentialTypes(credentialTypesRaw.([]string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		roleEntry.CredentialTypes = credentialTypes
+	} else if newRole {
+		roleEntry.CredentialTypes = defaultCredentialTypes
+	}
+
+	if nameTemplate, ok := d.GetOk("name_template"); ok {
+		roleEntry.NameTemplate = nameTemplate.(string)
+	}
+
+	if err := validateKeyNameTemplate(roleEntry.NameTemplate, name); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if disabled, ok := d.GetOk("disabled"); ok {
+		roleEntry.Disabled = disabled.(bool)
+	}
+
+	if serviceAccountID, ok := d.GetOk("service_account_id"); ok {
+		roleEntry.ServiceAccountID = serviceAccountID.(string)
+	}
+
+	if roleIDs, ok := d.GetOk("datadog_role_ids"); ok {
+		roleEntry.DatadogRoleIDs = roleIDs.([]string)
+	}
+
+	if emailTemplate, ok := d.GetOk("email_template"); ok {
+		roleEntry.EmailTemplate = emailTemplate.(string)
+	}
+
+	if emailMetadataKey, ok := d.GetOk("email_metadata_key"); ok {
+		roleEntry.EmailMetadataKey = emailMetadataKey.(string)
+	}
+
+	if datadogRoleID, ok := d.GetOk("datadog_role_id"); ok {
+		roleEntry.DatadogRoleID = datadogRoleID.(string)
+	}
+
+	if roleEntry.allowsCredentialType(credentialTypeElevation) && roleEntry.DatadogRoleID == "" {
+		return logical.ErrorResponse("datadog_role_id is required to issue elevations"), nil
+	}
+
+	if roleEntry.allowsCredentialType(credentialTypeUser) && len(roleEntry.DatadogRoleIDs) == 0 {
+		return logical.ErrorResponse("datadog_role_ids is required to issue users"), nil
+	}
+
+	if roleEntry.allowsCredentialType(credentialTypeServiceAccount) {
+		if len(roleEntry.DatadogRoleIDs) == 0 {
+			return logical.ErrorResponse("datadog_role_ids is required to issue service accounts"), nil
+		}
+		if err := validateEmailTemplate(roleEntry.EmailTemplate, name); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if maxRequestsRaw, ok := d.GetOk("max_requests_per_minute"); ok {
+		roleEntry.MaxRequestsPerMinute = maxRequestsRaw.(int)
+	}
+
+	if roleEntry.MaxRequestsPerMinute < 0 {
+		return logical.ErrorResponse("max_requests_per_minute cannot be negative"), nil
+	}
+
+	if maxActiveKeysRaw, ok := d.GetOk("max_active_keys"); ok {
+		roleEntry.MaxActiveKeys = maxActiveKeysRaw.(int)
+	}
+
+	if roleEntry.MaxActiveKeys < 0 {
+		return logical.ErrorResponse("max_active_keys cannot be negative"), nil
+	}
+
 	if ttlRaw, ok := d.GetOk("ttl"); ok {
 		roleEntry.TTL = time.Duration(ttlRaw.(int)) * time.Second
 	} else if createOperation {
@@ -218,12 +378,14 @@ func (b *datadogBackend) pathRolesDelete(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("error deleting datadog role: %w", err)
 	}
 
+	b.limiter.forgetRole(d.Get("name").(string))
+
 	return nil, nil
 }
 
 func (b *datadogBackend) PathRolesExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
 
-	out, err := req.Storage.Get(ctx, pathRoleDef)
+	out, err := req.Storage.Get(ctx, pathRoleDef+data.Get("name").(string))
 	if err != nil {
 		return false, fmt.Errorf("existence check failed: %w", err)
 	}
@@ -281,9 +443,72 @@ func validateScopes(scopes []string) error {
 func (r *datadogRoleEntry) toResponseData() map[string]interface{} {
 
 	return map[string]interface{}{
-		"app_key_scopes": r.AppKeyScopes,
-		"ttl":            r.TTL.Seconds(),
-		"max_ttl":        r.MaxTTL.Seconds(),
+		"app_key_scopes":          r.AppKeyScopes,
+		"credential_types":        r.credentialTypes(),
+		"name_template":           r.NameTemplate,
+		"disabled":                r.Disabled,
+		"max_requests_per_minute": r.MaxRequestsPerMinute,
+		"max_active_keys":         r.MaxActiveKeys,
+		"service_account_id":      r.ServiceAccountID,
+		"datadog_role_ids":        r.DatadogRoleIDs,
+		"email_template":          r.EmailTemplate,
+		"email_metadata_key":      r.emailMetadataKey(),
+		"datadog_role_id":         r.DatadogRoleID,
+		"ttl":                     r.TTL.Seconds(),
+		"max_ttl":                 r.MaxTTL.Seconds(),
+	}
+
+}
+
+// credentialTypes returns the credential types the role may issue.
+// Roles written before credential types existed may issue all of them.
+func (r *datadogRoleEntry) credentialTypes() []string {
+	if len(r.CredentialTypes) == 0 {
+		return defaultCredentialTypes
+	}
+	return r.CredentialTypes
+}
+
+// emailMetadataKey returns the entity metadata key holding
+// the email of the users issued from the role
+func (r *datadogRoleEntry) emailMetadataKey() string {
+	if r.EmailMetadataKey == "" {
+		return defaultEmailMetadataKey
+	}
+	return r.EmailMetadataKey
+}
+
+// allowsCredentialType reports whether the role may issue
+// credentials of the given type
+func (r *datadogRoleEntry) allowsCredentialType(credentialType string) bool {
+	return contains(r.credentialTypes(), credentialType)
+}
+
+// parseCredentialTypes validates the provided credential types,
+// expanding "both" into the API and App key types
+func parseCredentialTypes(raw []string) ([]string, error) {
+
+	var credentialTypes []string
+	for _, t := range raw {
+		switch t {
+		case credentialTypeBoth:
+			for _, dt := range defaultCredentialTypes {
+				if !contains(credentialTypes, dt) {
+					credentialTypes = append(credentialTypes, dt)
+				}
+			}
+		case credentialTypeAPIKey, credentialTypeAppKey, credentialTypeServiceAccount, credentialTypeUser, credentialTypeElevation:
+			if !contains(credentialTypes, t) {
+				credentialTypes = append(credentialTypes, t)
+			}
+		default:
+			return nil, fmt.Errorf("provided credential type %s is not valid, must be one of api_key, app_key, both, service_account, user or elevation", t)
+		}
+	}
+
+	if len(credentialTypes) == 0 {
+		return nil, fmt.Errorf("at least one credential type must be provided")
 	}
 
+	return credentialTypes, nil
 }
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	pathRoleRevokeAllHelpSynopsis    = "Delete every outstanding key issued from a role"
+	pathRoleRevokeAllHelpDescription = `
+	This path deletes the datadog keys of every outstanding lease
+	issued from the role and reports the result for each key. The
+	leases themselves remain until they expire or are revoked, which
+	then completes without contacting datadog. Set disable_role to
+	stop the role from issuing new keys until it is written with
+	disabled=false.
+	`
+)
+
+func pathRoleRevokeAll(b *datadogBackend) *framework.Path {
+
+	return &framework.Path{
+		Pattern: pathRoleDef + framework.GenericNameRegex("name") + "/revoke-all$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Required. Name of the role",
+				Required:    true,
+			},
+			"disable_role": {
+				Type:        framework.TypeBool,
+				Description: "Optional. Disable the role so that it cannot issue new keys.",
+				Default:     false,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRoleRevokeAll,
+				Summary:  "Delete every outstanding key issued from a role",
+			},
+		},
+		HelpSynopsis:    pathRoleRevokeAllHelpSynopsis,
+		HelpDescription: pathRoleRevokeAllHelpDescription,
+	}
+}
+
+func (b *datadogBackend) pathRoleRevokeAll(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+	disableRole := d.Get("disable_role").(bool)
+
+	// keys of a deleted role can still be revoked,
+	// but only an existing role can be disabled
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if disableRole {
+		if roleEntry == nil {
+			return logical.ErrorResponse("role %q not found", roleName), nil
+		}
+
+		// disable the role first so that no keys are
+		// issued while the existing ones are revoked
+		roleEntry.Disabled = true
+		if err := setRole(ctx, req.Storage, roleName, roleEntry); err != nil {
+			return nil, fmt.Errorf("error disabling role: %w", err)
+		}
+	}
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	keys, err := listIssuedKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	results := make([]map[string]interface{}, 0)
+	revoked, failed := 0, 0
+	for _, k := range keys {
+		if k.Role != roleName || k.Revoked {
+			continue
+		}
+
+		result := map[string]interface{}{
+			"key_id":   k.KeyID,
+			"key_type": k.KeyType,
+			"lease_id": k.LeaseID,
+			"revoked":  false,
+		}
+		results = append(results, result)
+
+		// a key that is already gone from datadog is as good as revoked
+		if err := deleteIssuedDatadogKey(ctx, client, k.KeyType, k.KeyID, k.ServiceAccountID); err != nil && statusCode(err) != http.StatusNotFound {
+			b.Logger().Warn("error revoking key", "role", roleName, "key_id", k.KeyID, "error", err)
+			result["error"] = err.Error()
+			failed++
+			continue
+		}
+
+		// the lease of a key whose revocation failed has already
+		// ended, so nothing would ever delete a revoked record
+		if k.RevocationError != "" {
+			if err := b.deleteIssuedKey(ctx, req.Storage, k.KeyID); err != nil {
+				return nil, fmt.Errorf("error deleting revoked key: %w", err)
+			}
+		} else {
+			k.Revoked = true
+			if err := b.putIssuedKey(ctx, req.Storage, k); err != nil {
+				return nil, fmt.Errorf("error recording revoked key: %w", err)
+			}
+		}
+		result["revoked"] = true
+		revoked++
+	}
+
+	b.Logger().Info("revoked all keys of role", "role", roleName, "revoked", revoked, "failed", failed)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"keys":          results,
+			"revoked":       revoked,
+			"failed":        failed,
+			"role_disabled": roleEntry != nil && roleEntry.Disabled,
+		},
+	}, nil
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleRevokeAll(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	for _, name := range []string{roleName, "other"} {
+		_, err := testTokenRoleCreate(t, b, s, name, map[string]interface{}{
+			"app_key_scopes": scopes,
+		})
+		require.NoError(t, err)
+	}
+
+	issue := func(t *testing.T, path string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Storage:   s,
+		})
+	}
+
+	var secrets []*logical.Secret
+	for _, path := range []string{apiKeyPath + roleName, credsPath + roleName} {
+		resp, err := issue(t, path)
+		require.NoError(t, err)
+		secrets = append(secrets, resp.Secret)
+	}
+	otherResp, err := issue(t, appKeyPath+"other")
+	require.NoError(t, err)
+
+	// a key that is already gone from datadog
+	delete(srv.apiKeys, secrets[1].InternalData["api_key_id"].(string))
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      pathRoleDef + roleName + "/revoke-all",
+		Data:      map[string]interface{}{"disable_role": true},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	require.Len(t, resp.Data["keys"], 3)
+	require.Equal(t, 3, resp.Data["revoked"])
+	require.Equal(t, 0, resp.Data["failed"])
+	require.Equal(t, true, resp.Data["role_disabled"])
+
+	apiKeys, appKeys := srv.keyCount()
+	require.Zero(t, apiKeys)
+	require.Equal(t, 1, appKeys)
+	require.Contains(t, srv.appKeys, otherResp.Secret.InternalData["app_key_id"])
+
+	t.Run("Issuance Disabled", func(t *testing.T) {
+		_, err := issue(t, apiKeyPath+roleName)
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+	})
+
+	t.Run("Not Listed", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      pathRoleDef + roleName + "/keys",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Empty(t, resp.Data["keys"])
+	})
+
+	t.Run("Renew Revoked Lease", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Secret:    secrets[0],
+			Storage:   s,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Revoke Revoked Lease", func(t *testing.T) {
+		for _, secret := range secrets {
+			_, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.RevokeOperation,
+				Secret:    secret,
+				Storage:   s,
+			})
+			require.NoError(t, err)
+		}
+
+		keys, err := listIssuedKeys(context.Background(), s)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+	})
+
+	t.Run("Re-enable Role", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathRoleDef + roleName,
+			Data:      map[string]interface{}{"disabled": false},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		resp, err := issue(t, apiKeyPath+roleName)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+	})
+
+	t.Run("Failed Revocation", func(t *testing.T) {
+		resp, err := issue(t, apiKeyPath+roleName)
+		require.NoError(t, err)
+		keyID := resp.Secret.InternalData["api_key_id"].(string)
+
+		srv.deleteStatus = http.StatusForbidden
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		srv.deleteStatus = 0
+		require.NoError(t, err)
+
+		// the key's lease has ended, so its record is deleted
+		// rather than left revoked
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      pathRoleDef + roleName + "/revoke-all",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, resp.Data["revoked"])
+		require.NotContains(t, srv.apiKeys, keyID)
+
+		k, err := getIssuedKey(context.Background(), s, keyID)
+		require.NoError(t, err)
+		require.Nil(t, k)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
 )
@@ -154,3 +155,162 @@ func testTokenRoleDelete(t *testing.T, b *datadogBackend, s logical.Storage) (*l
 		Storage:   s,
 	})
 }
+
+// TestDatadogRoleCredentialTypes checks that roles only
+// issue the credential types they permit
+func TestDatadogRoleCredentialTypes(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	t.Run("Invalid Credential Type", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"credential_types": "client_secret",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Default Credential Types", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{})
+		require.NoError(t, err)
+
+		resp, err := testTokenRoleRead(t, b, s)
+		require.NoError(t, err)
+		require.Equal(t, []string{"api_key", "app_key"}, resp.Data["credential_types"])
+	})
+
+	t.Run("App Key Only", func(t *testing.T) {
+		_, err := testTokenRoleUpdate(t, b, s, map[string]interface{}{
+			"credential_types": "app_key",
+		})
+		require.NoError(t, err)
+
+		for path, allowed := range map[string]bool{
+			apiKeyPath: false,
+			appKeyPath: true,
+			credsPath:  false,
+		} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      path + roleName,
+				Storage:   s,
+			})
+			if allowed {
+				require.NoError(t, err)
+				require.NotNil(t, resp.Secret)
+			} else {
+				require.ErrorIs(t, err, logical.ErrPermissionDenied)
+			}
+		}
+	})
+
+	t.Run("Update Keeps Credential Types", func(t *testing.T) {
+		exists, err := b.PathRolesExistenceCheck(context.Background(), &logical.Request{
+			Storage: s,
+		}, &framework.FieldData{
+			Raw:    map[string]interface{}{"name": roleName},
+			Schema: map[string]*framework.FieldSchema{"name": {Type: framework.TypeString}},
+		})
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		for _, op := range []logical.Operation{logical.UpdateOperation, logical.CreateOperation} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: op,
+				Path:      "roles/" + roleName,
+				Data:      map[string]interface{}{"disabled": false},
+				Storage:   s,
+			})
+			require.NoError(t, err)
+			require.Nil(t, resp)
+
+			resp, err = testTokenRoleRead(t, b, s)
+			require.NoError(t, err)
+			require.Equal(t, []string{"app_key"}, resp.Data["credential_types"])
+		}
+	})
+}
+
+// TestDatadogRoleNameTemplate checks that generated keys
+// are named from the role's name template
+func TestDatadogRoleNameTemplate(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	t.Run("Invalid Template", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"name_template": "{{ .RoleName",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Name Too Long", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"name_template": "{{ .RoleName }}-{{ random 300 }}",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Render Template", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"name_template": "vault-{{ .RoleName }}-{{ .DisplayName | truncate 10 }}",
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        apiKeyPath + roleName,
+			DisplayName: "token-ci-deployer",
+			Storage:     s,
+		})
+		require.NoError(t, err)
+
+		apiKeyID := resp.Secret.InternalData["api_key_id"].(string)
+		require.Equal(t, "vault-testdatadog-token-ci-d", srv.apiKeys[apiKeyID].Name)
+	})
+}
+
+func TestDatadogRoleServiceAccount(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	const serviceAccountID = "7b0e1a4c-5d3f-4c1e-9a2b-8f6d0c3e1a5b"
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes":     scopes,
+		"service_account_id": serviceAccountID,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	require.Equal(t, serviceAccountID, resp.Data["service_account_id"])
+
+	for _, path := range []string{appKeyPath, credsPath} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		appKeyID := resp.Secret.InternalData["app_key_id"].(string)
+		require.Equal(t, serviceAccountID, srv.appKeys[appKeyID].Owner)
+		require.Equal(t, serviceAccountID, resp.Secret.InternalData["service_account_id"])
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotContains(t, srv.appKeys, appKeyID)
+	}
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	serviceAccountPath        = "serviceaccount/"
+	pathServiceAccountHelpSyn = `
+	Generate a datadog service account from a role.
+	`
+	pathServiceAccountHelpDesc = `
+	This path creates a datadog service account holding the datadog
+	roles of a particular role, along with an Application Key owned
+	by the service account. When the lease ends, the Application Key
+	is deleted and the service account is disabled.
+	`
+)
+
+func pathServiceAccount(b *datadogBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: serviceAccountPath + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathServiceAccountRead,
+			logical.UpdateOperation: b.pathServiceAccountRead,
+		},
+		HelpSynopsis:    pathServiceAccountHelpSyn,
+		HelpDescription: pathServiceAccountHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathServiceAccountRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	if !roleEntry.allowsCredentialType(credentialTypeServiceAccount) {
+		return logical.ErrorResponse("role %q is not permitted to issue service accounts", roleName), logical.ErrPermissionDenied
+	}
+
+	if roleEntry.Disabled {
+		return logical.ErrorResponse("role %q is disabled", roleName), logical.ErrPermissionDenied
+	}
+
+	// the service account and its application key
+	if resp, err := b.limitKeyCreation(ctx, req.Storage, roleEntry, 2); resp != nil || err != nil {
+		return resp, err
+	}
+
+	release, limitResp, err := b.limitActiveKeys(ctx, req.Storage, roleEntry, 1)
+	if limitResp != nil || err != nil {
+		return limitResp, err
+	}
+	defer release()
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	name, err := generateKeyName(ctx, req, roleEntry)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	email, err := generateEmail(req, roleEntry)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	serviceAccount, err := client.createServiceAccount(ctx, email, name, roleEntry.DatadogRoleIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error creating datadog service account: %w", err)
+	}
+
+	appKey, err := client.createServiceAccountAppKey(ctx, serviceAccount.ID, name, roleEntry.AppKeyScopes)
+	if err != nil {
+		if rollbackErr := client.disableUser(ctx, serviceAccount.ID); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog service account", "service_account_id", serviceAccount.ID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error creating datadog application key: %w", err)
+	}
+
+	issued := b.newIssuedKey(req, roleEntry, credentialTypeServiceAccount, appKey.AppKeyID)
+	issued.ServiceAccountID = serviceAccount.ID
+	if err := b.putIssuedKey(ctx, req.Storage, issued); err != nil {
+		if rollbackErr := deleteServiceAccount(ctx, client, serviceAccount.ID, appKey.AppKeyID); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog service account", "service_account_id", serviceAccount.ID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error recording issued service account: %w", err)
+	}
+
+	resp := b.Secret(datadogServiceAccountType).Response(map[string]interface{}{
+		"service_account_id": serviceAccount.ID,
+		"email":              serviceAccount.Email,
+		"app_key":            appKey.AppKey,
+	}, issued.internalData(map[string]interface{}{
+		"app_key_id":         appKey.AppKeyID,
+		"role":               roleEntry.Name,
+		"service_account_id": serviceAccount.ID,
+	}))
+
+	if roleEntry.TTL > 0 {
+		resp.Secret.TTL = roleEntry.TTL
+	}
+
+	if roleEntry.MaxTTL > 0 {
+		resp.Secret.MaxTTL = roleEntry.MaxTTL
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccount(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	datadogRoleIDs := []string{"role-read-only", "role-monitors"}
+
+	t.Run("Invalid Role", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"credential_types": []string{credentialTypeServiceAccount},
+			"email_template":   "vault-{{ .RoleName }}@example.com",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+
+		resp, err = testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"credential_types": []string{credentialTypeServiceAccount},
+			"datadog_role_ids": datadogRoleIDs,
+			"email_template":   "{{ .RoleName }}",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes":   scopes,
+		"credential_types": []string{credentialTypeServiceAccount},
+		"datadog_role_ids": datadogRoleIDs,
+		"email_template":   "vault-{{ .RoleName }}-{{ uuid }}@example.com",
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	issue := func(t *testing.T) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      serviceAccountPath + roleName,
+			Storage:   s,
+		})
+	}
+
+	t.Run("Keys Not Permitted", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      appKeyPath + roleName,
+			Storage:   s,
+		})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+	})
+
+	t.Run("Issue And Revoke", func(t *testing.T) {
+		resp, err := issue(t)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		serviceAccountID := resp.Data["service_account_id"].(string)
+		appKeyID := resp.Secret.InternalData["app_key_id"].(string)
+
+		require.Contains(t, srv.users, serviceAccountID)
+		serviceAccount := srv.users[serviceAccountID]
+		require.True(t, serviceAccount.ServiceAccount)
+		require.Equal(t, datadogRoleIDs, serviceAccount.Roles)
+		require.Equal(t, serviceAccount.Email, resp.Data["email"])
+		require.Regexp(t, `^vault-`+roleName+`-[0-9a-f-]+@example\.com$`, serviceAccount.Email)
+
+		require.Contains(t, srv.appKeys, appKeyID)
+		require.Equal(t, serviceAccountID, srv.appKeys[appKeyID].Owner)
+		require.Equal(t, srv.appKeys[appKeyID].Key, resp.Data["app_key"])
+
+		issued, err := getIssuedKey(context.Background(), s, appKeyID)
+		require.NoError(t, err)
+		require.Equal(t, credentialTypeServiceAccount, issued.KeyType)
+		require.Equal(t, serviceAccountID, issued.ServiceAccountID)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotContains(t, srv.appKeys, appKeyID)
+		require.True(t, serviceAccount.Disabled)
+
+		issued, err = getIssuedKey(context.Background(), s, appKeyID)
+		require.NoError(t, err)
+		require.Nil(t, issued)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		srv.failAppKeyCreate = true
+		defer func() { srv.failAppKeyCreate = false }()
+
+		_, err := issue(t)
+		require.Error(t, err)
+
+		for _, u := range srv.users {
+			require.True(t, u.Disabled)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	staticCredsPath        = "static-creds/"
+	pathStaticCredsHelpSyn = `
+	Read the current key of a static role.
+	`
+	pathStaticCredsHelpDesc = `
+	This path returns the current datadog API or Application Key
+	managed by a static role, along with the time until it is
+	next rotated.
+	`
+)
+
+func pathStaticCreds(b *datadogBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: staticCredsPath + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the static role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredsRead,
+		},
+		HelpSynopsis:    pathStaticCredsHelpSyn,
+		HelpDescription: pathStaticCredsHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathStaticCredsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := getStaticRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving static role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("static role %q not found", roleName), nil
+	}
+
+	ttl := time.Until(roleEntry.nextRotation())
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_type":     roleEntry.KeyType,
+			"key_id":       roleEntry.KeyID,
+			"key":          roleEntry.Key,
+			"last_rotated": roleEntry.LastRotated.Format(time.RFC3339),
+			"ttl":          int64(ttl.Seconds()),
+		},
+	}, nil
+}
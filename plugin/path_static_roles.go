@@ -0,0 +1,416 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	pathStaticRoleDef             = "static-roles/"
+	pathStaticRoleHelpSynopsis    = "Manages Vault static roles bound to existing Datadog API and Application Keys."
+	pathStaticRoleHelpDescription = `
+	This path allows you to read and write static roles. A static role is bound to an
+	existing Datadog API or Application Key, which Vault rotates every rotation_period
+	by creating a replacement with the same name and deleting the old key. Datadog
+	can't regenerate a key in place, so the key_id of the role changes with every
+	rotation. The current key and its key_id can be read from the static-creds
+	endpoint.
+
+	If the current key value is not provided, the key is rotated when the role is
+	created so that Vault knows its value. Application Keys must be owned by the user
+	that owns the root Application Key.
+	`
+	pathStaticRoleListHelpSynopsis    = "List the existing static roles in datadog backend"
+	pathStaticRoleListHelpDescription = "Static roles will be listed by the role name."
+	minStaticRotationPeriod           = time.Minute
+)
+
+// datadogStaticRoleEntry defines a Vault static role
+// managing an existing datadog key
+type datadogStaticRoleEntry struct {
+	Name           string        `json:"name"`
+	KeyType        string        `json:"key_type"`
+	KeyID          string        `json:"key_id"`
+	Key            string        `json:"key"`
+	RotationPeriod time.Duration `json:"rotation_period"`
+	LastRotated    time.Time     `json:"last_rotated"`
+}
+
+// pathStaticRole defines the framework.Path for datadog static roles
+func pathStaticRole(b *datadogBackend) []*framework.Path {
+
+	return []*framework.Path{
+		{
+			Pattern: pathStaticRoleDef + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Required. Name of the static role",
+					Required:    true,
+				},
+				"key_type": {
+					Type:          framework.TypeString,
+					Description:   "Required. Type of the managed key: api_key or app_key.",
+					AllowedValues: []interface{}{credentialTypeAPIKey, credentialTypeAppKey},
+				},
+				"key_id": {
+					Type:        framework.TypeString,
+					Description: "Required. ID of the existing datadog key managed by the role. It changes with every rotation.",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "Optional. Current value of the managed key. If not provided, the key is rotated when the role is created.",
+					DisplayAttrs: &framework.DisplayAttributes{
+						Sensitive: true,
+					},
+				},
+				"rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Required. Time between rotations of the managed key. Minimum of one minute.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesDelete,
+				},
+			},
+			HelpSynopsis:    pathStaticRoleHelpSynopsis,
+			HelpDescription: pathStaticRoleHelpDescription,
+			ExistenceCheck:  b.pathStaticRolesExistenceCheck,
+		},
+		{
+			Pattern: pathStaticRoleDef + "?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesList,
+				},
+			},
+			HelpSynopsis:    pathStaticRoleListHelpSynopsis,
+			HelpDescription: pathStaticRoleListHelpDescription,
+		},
+	}
+}
+
+// pathStaticRolesList lists the datadog static roles
+func (b *datadogBackend) pathStaticRolesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	entries, err := req.Storage.List(ctx, pathStaticRoleDef)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// pathStaticRolesRead returns a specific datadog static role
+func (b *datadogBackend) pathStaticRolesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	entry, err := getStaticRole(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: entry.toResponseData(),
+	}, nil
+}
+
+// pathStaticRolesWrite creates or updates a datadog static role
+func (b *datadogBackend) pathStaticRolesWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	name := d.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	roleEntry, err := getStaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	createOperation := (req.Operation == logical.CreateOperation) || roleEntry == nil
+	if roleEntry == nil {
+		roleEntry = &datadogStaticRoleEntry{}
+	}
+
+	roleEntry.Name = name
+
+	keyChanged := false
+
+	if keyType, ok := d.GetOk("key_type"); ok {
+		if !createOperation && keyType.(string) != roleEntry.KeyType {
+			return logical.ErrorResponse("key_type cannot be changed"), nil
+		}
+		roleEntry.KeyType = keyType.(string)
+	}
+	if roleEntry.KeyType != credentialTypeAPIKey && roleEntry.KeyType != credentialTypeAppKey {
+		return logical.ErrorResponse("key_type must be one of api_key or app_key"), nil
+	}
+
+	if keyID, ok := d.GetOk("key_id"); ok {
+		if !createOperation && keyID.(string) != roleEntry.KeyID {
+			return logical.ErrorResponse("key_id cannot be changed"), nil
+		}
+		roleEntry.KeyID = keyID.(string)
+	}
+	if roleEntry.KeyID == "" {
+		return logical.ErrorResponse("missing key_id"), nil
+	}
+
+	if key, ok := d.GetOk("key"); ok {
+		roleEntry.Key = key.(string)
+		keyChanged = true
+	}
+
+	if periodRaw, ok := d.GetOk("rotation_period"); ok {
+		roleEntry.RotationPeriod = time.Duration(periodRaw.(int)) * time.Second
+	}
+	if roleEntry.RotationPeriod < minStaticRotationPeriod {
+		return logical.ErrorResponse("rotation_period must be at least %s", minStaticRotationPeriod), nil
+	}
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	// make sure the key exists and, if provided, that its value
+	// belongs to the key ID
+	details, err := getKeyDetails(ctx, client, roleEntry.KeyType, roleEntry.KeyID)
+	if err != nil {
+		return logical.ErrorResponse("error reading datadog key %s: %s", roleEntry.KeyID, err), nil
+	}
+	if keyChanged && !strings.HasSuffix(roleEntry.Key, details.Last4) {
+		return logical.ErrorResponse("key does not match key ID %s", roleEntry.KeyID), nil
+	}
+	if keyChanged {
+		roleEntry.LastRotated = time.Now().UTC()
+	}
+
+	if err := setStaticRole(ctx, req.Storage, roleEntry); err != nil {
+		return nil, err
+	}
+
+	// without the current value, rotate so that vault knows the key
+	if roleEntry.Key == "" {
+		if err := b.rotateStaticRole(ctx, req.Storage, roleEntry); err != nil {
+			return nil, fmt.Errorf("error rotating static role: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// pathStaticRolesDelete deletes a datadog static role. The managed
+// key is left in datadog.
+func (b *datadogBackend) pathStaticRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	err := req.Storage.Delete(ctx, pathStaticRoleDef+d.Get("name").(string))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting datadog static role: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *datadogBackend) pathStaticRolesExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+
+	out, err := req.Storage.Get(ctx, pathStaticRoleDef+data.Get("name").(string))
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+	return out != nil, nil
+}
+
+// rotateStaticRole replaces the key managed by a static role with a new
+// key of the same name, stores it and deletes the old key
+func (b *datadogBackend) rotateStaticRole(ctx context.Context, s logical.Storage, roleEntry *datadogStaticRoleEntry) error {
+
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	details, err := getKeyDetails(ctx, client, roleEntry.KeyType, roleEntry.KeyID)
+	if err != nil {
+		return err
+	}
+
+	wal := &walStaticRoleRotation{
+		Role:     roleEntry.Name,
+		KeyType:  roleEntry.KeyType,
+		OldKeyID: roleEntry.KeyID,
+	}
+	walID, err := framework.PutWAL(ctx, s, walStaticRoleRotationKind, wal)
+	if err != nil {
+		return fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	switch roleEntry.KeyType {
+	case credentialTypeAPIKey:
+		apiKey, err := createAPIKey(ctx, client, details.Name)
+		if err != nil {
+			return err
+		}
+		wal.NewKeyID = apiKey.APIKeyID
+		roleEntry.KeyID, roleEntry.Key = apiKey.APIKeyID, apiKey.APIKey
+	case credentialTypeAppKey:
+		scopes := details.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		appKey, err := createAppKey(ctx, client, details.Name, scopes)
+		if err != nil {
+			return err
+		}
+		wal.NewKeyID = appKey.AppKeyID
+		roleEntry.KeyID, roleEntry.Key = appKey.AppKeyID, appKey.AppKey
+	}
+
+	if walID, err = replaceWAL(ctx, s, walID, walStaticRoleRotationKind, wal); err != nil {
+		return fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	roleEntry.LastRotated = time.Now().UTC()
+	if err := setStaticRole(ctx, s, roleEntry); err != nil {
+		return err
+	}
+
+	if err := deleteKey(ctx, client, roleEntry.KeyType, wal.OldKeyID); err != nil && statusCode(err) != http.StatusNotFound {
+		return err
+	}
+
+	if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+		return fmt.Errorf("error deleting WAL entry: %w", err)
+	}
+
+	return nil
+}
+
+// rotateDueStaticRoles rotates every static role whose
+// rotation period has elapsed
+func (b *datadogBackend) rotateDueStaticRoles(ctx context.Context, s logical.Storage) error {
+
+	names, err := s.List(ctx, pathStaticRoleDef)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		roleEntry, err := getStaticRole(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		if roleEntry == nil || now.Before(roleEntry.nextRotation()) {
+			continue
+		}
+
+		if err := b.rotateStaticRole(ctx, s, roleEntry); err != nil {
+			b.Logger().Error("error rotating static role", "role", name, "error", err)
+			continue
+		}
+		b.Logger().Info("rotated static role", "role", name, "key_id", roleEntry.KeyID)
+	}
+
+	return nil
+}
+
+// getStaticRole gets the static role from the Vault storage API
+func getStaticRole(ctx context.Context, s logical.Storage, name string) (*datadogStaticRoleEntry, error) {
+
+	if name == "" {
+		return nil, fmt.Errorf("missing static role name")
+	}
+
+	entry, err := s.Get(ctx, pathStaticRoleDef+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role datadogStaticRoleEntry
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// setStaticRole sets the static role into the Vault storage API
+func setStaticRole(ctx context.Context, s logical.Storage, roleEntry *datadogStaticRoleEntry) error {
+
+	entry, err := logical.StorageEntryJSON(pathStaticRoleDef+roleEntry.Name, roleEntry)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for static role")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getKeyDetails reads a datadog key of the given credential type
+func getKeyDetails(ctx context.Context, c *datadogClient, keyType string, keyID string) (*datadogKeyDetails, error) {
+
+	switch keyType {
+	case credentialTypeAPIKey:
+		return c.getAPIKey(ctx, keyID)
+	case credentialTypeAppKey:
+		return c.getAppKey(ctx, keyID)
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+}
+
+// deleteKey deletes a datadog key of the given credential type
+func deleteKey(ctx context.Context, c *datadogClient, keyType string, keyID string) error {
+
+	switch keyType {
+	case credentialTypeAPIKey:
+		return deleteAPIKey(ctx, c, keyID)
+	case credentialTypeAppKey:
+		return deleteAppKey(ctx, c, keyID)
+	default:
+		return fmt.Errorf("unknown key type %q", keyType)
+	}
+}
+
+// nextRotation returns the time the static role is next due for rotation
+func (r *datadogStaticRoleEntry) nextRotation() time.Time {
+	return r.LastRotated.Add(r.RotationPeriod)
+}
+
+// toResponseData returns response data for a datadog static role
+func (r *datadogStaticRoleEntry) toResponseData() map[string]interface{} {
+
+	return map[string]interface{}{
+		"key_type":        r.KeyType,
+		"key_id":          r.KeyID,
+		"rotation_period": r.RotationPeriod.Seconds(),
+		"last_rotated":    r.LastRotated.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRoles(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	apiKeyID := srv.addKey(srv.apiKeys, "agent")
+	apiKey := srv.apiKeys[apiKeyID].Key
+	appKeyID := srv.addKey(srv.appKeys, "reporting")
+
+	readStaticCreds := func(t *testing.T, name string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      staticCredsPath + name,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		return resp
+	}
+
+	t.Run("Bind Existing Key", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      pathStaticRoleDef + "agent",
+			Data: map[string]interface{}{
+				"key_type":        "api_key",
+				"key_id":          apiKeyID,
+				"key":             apiKey,
+				"rotation_period": "24h",
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		resp = readStaticCreds(t, "agent")
+		require.Equal(t, apiKeyID, resp.Data["key_id"])
+		require.Equal(t, apiKey, resp.Data["key"])
+	})
+
+	t.Run("Mismatched Key", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      pathStaticRoleDef + "mismatched",
+			Data: map[string]interface{}{
+				"key_type":        "api_key",
+				"key_id":          apiKeyID,
+				"key":             "not-the-key",
+				"rotation_period": "24h",
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Rotate On Create", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      pathStaticRoleDef + "reporting",
+			Data: map[string]interface{}{
+				"key_type":        "app_key",
+				"key_id":          appKeyID,
+				"rotation_period": "1h",
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+
+		resp := readStaticCreds(t, "reporting")
+		require.NotEqual(t, appKeyID, resp.Data["key_id"])
+		require.NotContains(t, srv.appKeys, appKeyID)
+		require.Equal(t, "reporting", srv.appKeys[resp.Data["key_id"].(string)].Name)
+	})
+
+	t.Run("Scheduled Rotation", func(t *testing.T) {
+		role, err := getStaticRole(context.Background(), s, "agent")
+		require.NoError(t, err)
+		role.LastRotated = time.Now().Add(-25 * time.Hour)
+		require.NoError(t, setStaticRole(context.Background(), s, role))
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		resp := readStaticCreds(t, "agent")
+		require.NotEqual(t, apiKeyID, resp.Data["key_id"])
+		require.NotEqual(t, apiKey, resp.Data["key"])
+		require.NotContains(t, srv.apiKeys, apiKeyID)
+		require.Equal(t, "agent", srv.apiKeys[resp.Data["key_id"].(string)].Name)
+	})
+
+	t.Run("List Static Roles", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      pathStaticRoleDef,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"agent", "reporting"}, resp.Data["keys"])
+	})
+}
@@ -0,0 +1,460 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	tidyPath                = "tidy"
+	autoTidyConfigPath      = "config/auto-tidy"
+	defaultTidySafetyBuffer = time.Hour
+	defaultAutoTidyInterval = 12 * time.Hour
+	pathTidyHelpSynopsis    = "Delete datadog keys that are no longer tracked by Vault"
+	pathTidyHelpDescription = `
+	This path lists the datadog API and Application Keys whose names
+	start with the key_name_prefix of the config and deletes those
+	that are not tracked by this backend and are older than
+	safety_buffer. The root keys, replaced root keys waiting to be
+	deleted and the keys of static roles are never deleted. Set
+	dry_run to report the orphaned keys without deleting them.
+	`
+	pathAutoTidyHelpSynopsis    = "Configure the periodic tidy of orphaned datadog keys"
+	pathAutoTidyHelpDescription = `
+	When enabled, the backend runs a tidy every interval using the
+	configured safety_buffer.
+	`
+)
+
+// tidyOptions controls which datadog keys a tidy considers orphaned
+type tidyOptions struct {
+	NamePrefix   string
+	SafetyBuffer time.Duration
+	DryRun       bool
+}
+
+// autoTidyConfig is the configuration of the periodic tidy
+type autoTidyConfig struct {
+	Enabled      bool          `json:"enabled"`
+	Interval     time.Duration `json:"interval"`
+	SafetyBuffer time.Duration `json:"safety_buffer"`
+	LastTidy     time.Time     `json:"last_tidy"`
+}
+
+func pathTidy(b *datadogBackend) []*framework.Path {
+
+	return []*framework.Path{
+		{
+			Pattern: tidyPath + "$",
+			Fields: map[string]*framework.FieldSchema{
+				"safety_buffer": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Optional. Minimum age of a key before it is considered orphaned. Defaults to 1h.",
+					Default:     int(defaultTidySafetyBuffer.Seconds()),
+				},
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "Optional. Report the orphaned keys without deleting them.",
+					Default:     false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathTidyWrite,
+					Summary:  "Delete orphaned datadog keys",
+				},
+			},
+			HelpSynopsis:    pathTidyHelpSynopsis,
+			HelpDescription: pathTidyHelpDescription,
+		},
+		{
+			Pattern: autoTidyConfigPath + "$",
+			Fields: map[string]*framework.FieldSchema{
+				"enabled": {
+					Type:        framework.TypeBool,
+					Description: "Optional. Run the tidy periodically.",
+				},
+				"interval": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Optional. Time between two periodic tidies. Defaults to 12h.",
+					Default:     int(defaultAutoTidyInterval.Seconds()),
+				},
+				"safety_buffer": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Optional. Minimum age of a key before it is considered orphaned. Defaults to 1h.",
+					Default:     int(defaultTidySafetyBuffer.Seconds()),
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathAutoTidyRead,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathAutoTidyWrite,
+				},
+			},
+			HelpSynopsis:    pathAutoTidyHelpSynopsis,
+			HelpDescription: pathAutoTidyHelpDescription,
+		},
+	}
+}
+
+func (b *datadogBackend) pathTidyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+
+	namePrefix, err := getKeyNamePrefix(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if namePrefix == "" {
+		return logical.ErrorResponse("key_name_prefix must be set on the config to tidy"), nil
+	}
+
+	opts := &tidyOptions{
+		NamePrefix:   namePrefix,
+		SafetyBuffer: time.Duration(data.Get("safety_buffer").(int)) * time.Second,
+		DryRun:       data.Get("dry_run").(bool),
+	}
+	if opts.SafetyBuffer < 0 {
+		return logical.ErrorResponse("safety_buffer must not be negative"), nil
+	}
+
+	orphans, err := b.tidyKeys(ctx, req.Storage, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := 0
+	for _, o := range orphans {
+		if o["deleted"] == true {
+			deleted++
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"orphaned_keys": orphans,
+			"deleted":       deleted,
+			"dry_run":       opts.DryRun,
+		},
+	}, nil
+}
+
+func (b *datadogBackend) pathAutoTidyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+
+	config, err := getAutoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	lastTidy := ""
+	if !config.LastTidy.IsZero() {
+		lastTidy = config.LastTidy.Format(time.RFC3339)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":       config.Enabled,
+			"interval":      int64(config.Interval.Seconds()),
+			"safety_buffer": int64(config.SafetyBuffer.Seconds()),
+			"last_tidy":     lastTidy,
+		},
+	}, nil
+}
+
+func (b *datadogBackend) pathAutoTidyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+
+	config, err := getAutoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabled.(bool)
+	}
+	if interval, ok := data.GetOk("interval"); ok {
+		config.Interval = time.Duration(interval.(int)) * time.Second
+	}
+	if safetyBuffer, ok := data.GetOk("safety_buffer"); ok {
+		config.SafetyBuffer = time.Duration(safetyBuffer.(int)) * time.Second
+	}
+
+	if config.Interval <= 0 {
+		return logical.ErrorResponse("interval must be positive"), nil
+	}
+	if config.SafetyBuffer < 0 {
+		return logical.ErrorResponse("safety_buffer must not be negative"), nil
+	}
+	if config.Enabled {
+		namePrefix, err := getKeyNamePrefix(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if namePrefix == "" {
+			return logical.ErrorResponse("key_name_prefix must be set on the config to enable auto-tidy"), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(autoTidyConfigPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// tidyKeys finds the datadog keys matching the name prefix of opts
+// that are not tracked by the backend and deletes them, unless
+// opts is a dry run
+func (b *datadogBackend) tidyKeys(ctx context.Context, s logical.Storage, opts *tidyOptions) ([]map[string]interface{}, error) {
+
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	tracked, err := trackedKeyIDs(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tracked keys: %w", err)
+	}
+
+	failed, err := failedIssuedKeys(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("error reading issued keys: %w", err)
+	}
+
+	apiKeys, err := client.listAPIKeys(ctx, opts.NamePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	appKeys, err := client.listAppKeys(ctx, opts.NamePrefix)
+	if statusCode(err) == http.StatusForbidden {
+		b.Logger().Warn("root application key may not list the application keys of the organization, only its own are tidied", "error", err)
+		appKeys, err = client.listCurrentUserAppKeys(ctx, opts.NamePrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-opts.SafetyBuffer)
+	orphans := make([]map[string]interface{}, 0)
+	for _, listed := range []struct {
+		keyType string
+		keys    []*datadogKeyDetails
+	}{
+		{credentialTypeAPIKey, apiKeys},
+		{credentialTypeAppKey, appKeys},
+	} {
+		keyType := listed.keyType
+		for _, k := range listed.keys {
+			// keys without a creation date can't be checked
+			// against the safety buffer
+			if !strings.HasPrefix(k.Name, opts.NamePrefix) || tracked[k.ID] ||
+				k.CreatedAt.IsZero() || k.CreatedAt.After(cutoff) {
+				continue
+			}
+
+			orphan := map[string]interface{}{
+				"key_id":     k.ID,
+				"key_type":   keyType,
+				"name":       k.Name,
+				"created_at": k.CreatedAt.Format(time.RFC3339),
+				"deleted":    false,
+			}
+			orphans = append(orphans, orphan)
+
+			if opts.DryRun {
+				continue
+			}
+
+			// a key whose revocation failed is deleted the way its
+			// lease would have, and its record goes with it
+			rec := failed[k.ID]
+			if rec != nil {
+				err = deleteIssuedDatadogKey(ctx, client, rec.KeyType, rec.KeyID, rec.ServiceAccountID)
+			} else {
+				err = deleteKey(ctx, client, keyType, k.ID)
+			}
+			if err != nil && statusCode(err) != http.StatusNotFound {
+				b.Logger().Warn("error deleting orphaned key", "key_id", k.ID, "key_type", keyType, "error", err)
+				orphan["error"] = err.Error()
+				continue
+			}
+			orphan["deleted"] = true
+
+			if rec != nil {
+				if err := b.deleteIssuedKey(ctx, s, rec.KeyID); err != nil {
+					b.Logger().Warn("error deleting issued key record", "key_id", k.ID, "error", err)
+					orphan["error"] = err.Error()
+					continue
+				}
+			}
+			b.Logger().Info("deleted orphaned key", "key_id", k.ID, "key_type", keyType, "name", k.Name)
+		}
+	}
+
+	return orphans, nil
+}
+
+// trackedKeyIDs returns the IDs of every datadog key the
+// backend knows about
+func trackedKeyIDs(ctx context.Context, s logical.Storage) (map[string]bool, error) {
+
+	tracked := make(map[string]bool)
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		tracked[config.APIKeyID] = true
+		tracked[config.AppKeyID] = true
+	}
+
+	issued, err := listIssuedKeys(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range issued {
+		// keys whose revocation failed are left for tidy to delete
+		if k.RevocationError == "" {
+			tracked[k.KeyID] = true
+		}
+	}
+
+	deletions, err := listPendingDeletions(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deletions {
+		tracked[d.KeyID] = true
+	}
+
+	names, err := s.List(ctx, pathStaticRoleDef)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		roleEntry, err := getStaticRole(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if roleEntry != nil {
+			tracked[roleEntry.KeyID] = true
+		}
+	}
+
+	return tracked, nil
+}
+
+// failedIssuedKeys returns the issued keys whose revocation
+// failed, by key ID
+func failedIssuedKeys(ctx context.Context, s logical.Storage) (map[string]*issuedKey, error) {
+
+	issued, err := listIssuedKeys(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := make(map[string]*issuedKey)
+	for _, k := range issued {
+		if k.RevocationError != "" {
+			failed[k.KeyID] = k
+		}
+	}
+
+	return failed, nil
+}
+
+// autoTidy runs a tidy when auto-tidy is enabled and the
+// interval has passed since the last one
+func (b *datadogBackend) autoTidy(ctx context.Context, s logical.Storage) error {
+
+	config, err := getAutoTidyConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if !config.Enabled || time.Since(config.LastTidy) < config.Interval {
+		return nil
+	}
+
+	// the prefix may have been removed from the
+	// config since auto-tidy was enabled
+	namePrefix, err := getKeyNamePrefix(ctx, s)
+	if err != nil {
+		return err
+	}
+	if namePrefix == "" {
+		b.Logger().Warn("auto-tidy skipped as key_name_prefix is not set on the config")
+		return nil
+	}
+
+	orphans, err := b.tidyKeys(ctx, s, &tidyOptions{
+		NamePrefix:   namePrefix,
+		SafetyBuffer: config.SafetyBuffer,
+	})
+	if err != nil {
+		return err
+	}
+	b.Logger().Info("tidied orphaned keys", "orphaned_keys", len(orphans))
+
+	config.LastTidy = time.Now().UTC()
+	entry, err := logical.StorageEntryJSON(autoTidyConfigPath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getAutoTidyConfig returns the auto-tidy configuration,
+// or its defaults if it was never written
+func getAutoTidyConfig(ctx context.Context, s logical.Storage) (*autoTidyConfig, error) {
+
+	config := &autoTidyConfig{
+		Interval:     defaultAutoTidyInterval,
+		SafetyBuffer: defaultTidySafetyBuffer,
+	}
+
+	entry, err := s.Get(ctx, autoTidyConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, fmt.Errorf("error reading auto-tidy configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// getKeyNamePrefix returns the key name prefix of the config,
+// which is empty if the backend isn't configured
+func getKeyNamePrefix(ctx context.Context, s logical.Storage) (string, error) {
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	if config == nil {
+		return "", nil
+	}
+
+	return config.KeyNamePrefix, nil
+}
@@ -0,0 +1,221 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTidy(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	tidy := func(t *testing.T, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      tidyPath,
+			Data:      data,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Missing Prefix", func(t *testing.T) {
+		resp := tidy(t, map[string]interface{}{})
+		require.True(t, resp.IsError())
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      autoTidyConfigPath,
+			Data:      map[string]interface{}{"enabled": true},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	err := testConfigUpdate(t, b, s, map[string]interface{}{
+		"key_name_prefix": "vault-",
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"app_key_scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	// a key tracked by the backend
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      credsPath + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+	trackedAPIKeyID := resp.Secret.InternalData["api_key_id"].(string)
+	require.Regexp(t, `^vault-`+roleName+`-`, srv.apiKeys[trackedAPIKeyID].Name)
+	srv.apiKeys[trackedAPIKeyID].Created = time.Now().Add(-24 * time.Hour)
+
+	// orphans older than the safety buffer, a recent orphan and an
+	// old key that does not match the prefix
+	orphanAPIKeyID := srv.addKey(srv.apiKeys, "vault-test-orphan")
+	srv.apiKeys[orphanAPIKeyID].Created = time.Now().Add(-24 * time.Hour)
+	orphanAppKeyID := srv.addKey(srv.appKeys, "vault-test-orphan")
+	srv.appKeys[orphanAppKeyID].Created = time.Now().Add(-24 * time.Hour)
+	orphanServiceAccountKeyID := srv.addKey(srv.appKeys, "vault-test-orphan")
+	srv.appKeys[orphanServiceAccountKeyID].Created = time.Now().Add(-24 * time.Hour)
+	srv.appKeys[orphanServiceAccountKeyID].Owner = "service-account"
+	recentAPIKeyID := srv.addKey(srv.apiKeys, "vault-test-recent")
+	otherAPIKeyID := srv.addKey(srv.apiKeys, "other")
+	srv.apiKeys[otherAPIKeyID].Created = time.Now().Add(-24 * time.Hour)
+
+	t.Run("Dry Run", func(t *testing.T) {
+		resp := tidy(t, map[string]interface{}{
+			"dry_run": true,
+		})
+		require.False(t, resp.IsError())
+		require.Len(t, resp.Data["orphaned_keys"], 3)
+		require.Equal(t, 0, resp.Data["deleted"])
+		require.Contains(t, srv.apiKeys, orphanAPIKeyID)
+		require.Contains(t, srv.appKeys, orphanAppKeyID)
+	})
+
+	t.Run("Delete Orphans", func(t *testing.T) {
+		resp := tidy(t, map[string]interface{}{})
+		require.False(t, resp.IsError())
+		require.Equal(t, 3, resp.Data["deleted"])
+
+		require.NotContains(t, srv.apiKeys, orphanAPIKeyID)
+		require.NotContains(t, srv.appKeys, orphanAppKeyID)
+		require.NotContains(t, srv.appKeys, orphanServiceAccountKeyID)
+		require.Contains(t, srv.apiKeys, trackedAPIKeyID)
+		require.Contains(t, srv.apiKeys, recentAPIKeyID)
+		require.Contains(t, srv.apiKeys, otherAPIKeyID)
+		require.Contains(t, srv.apiKeys, APIKeyID)
+		require.Contains(t, srv.appKeys, AppKeyID)
+	})
+
+	t.Run("Auto Tidy", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      autoTidyConfigPath,
+			Data: map[string]interface{}{
+				"enabled":       true,
+				"safety_buffer": "1m",
+			},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		srv.apiKeys[recentAPIKeyID].Created = time.Now().Add(-time.Hour)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotContains(t, srv.apiKeys, recentAPIKeyID)
+		require.Contains(t, srv.apiKeys, trackedAPIKeyID)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      autoTidyConfigPath,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, true, resp.Data["enabled"])
+		require.NotEmpty(t, resp.Data["last_tidy"])
+	})
+
+	t.Run("Failed Revocation", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, "limited", map[string]interface{}{
+			"max_active_keys": 1,
+		})
+		require.NoError(t, err)
+
+		issue := func(t *testing.T) *logical.Response {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      apiKeyPath + "limited",
+				Storage:   s,
+			})
+			require.NoError(t, err)
+			return resp
+		}
+
+		resp := issue(t)
+		require.False(t, resp.IsError())
+		keyID := resp.Secret.InternalData["api_key_id"].(string)
+
+		srv.deleteStatus = http.StatusForbidden
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		srv.deleteStatus = 0
+		require.NoError(t, err)
+		require.Contains(t, srv.apiKeys, keyID)
+
+		// the failed key does not count against the role
+		resp = issue(t)
+		require.False(t, resp.IsError())
+		activeKeyID := resp.Secret.InternalData["api_key_id"].(string)
+
+		srv.apiKeys[keyID].Created = time.Now().Add(-24 * time.Hour)
+		srv.apiKeys[activeKeyID].Created = time.Now().Add(-24 * time.Hour)
+		resp = tidy(t, map[string]interface{}{})
+		require.False(t, resp.IsError())
+		require.Equal(t, 1, resp.Data["deleted"])
+		require.NotContains(t, srv.apiKeys, keyID)
+		require.Contains(t, srv.apiKeys, activeKeyID)
+
+		k, err := getIssuedKey(context.Background(), s, keyID)
+		require.NoError(t, err)
+		require.Nil(t, k)
+	})
+
+	t.Run("Kept Root Keys", func(t *testing.T) {
+		rotate := func(t *testing.T, deleteOld bool) *logical.Response {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      pathConfigDef + "/rotate",
+				Data:      map[string]interface{}{"delete_old": deleteOld},
+				Storage:   s,
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError())
+			return resp
+		}
+
+		// the keys of an earlier rotation match the prefix
+		rotate(t, true)
+		resp := rotate(t, false)
+		keptAPIKeyID := resp.Data["old_api_key_id"].(string)
+		keptAppKeyID := resp.Data["old_app_key_id"].(string)
+		require.Regexp(t, `^vault-`, srv.apiKeys[keptAPIKeyID].Name)
+		srv.apiKeys[keptAPIKeyID].Created = time.Now().Add(-24 * time.Hour)
+		srv.appKeys[keptAppKeyID].Created = time.Now().Add(-24 * time.Hour)
+
+		resp = tidy(t, map[string]interface{}{})
+		require.False(t, resp.IsError())
+		require.Contains(t, srv.apiKeys, keptAPIKeyID)
+		require.Contains(t, srv.appKeys, keptAppKeyID)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      pathConfigDef + "/pending-deletions",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Empty(t, resp.Data["pending_deletions"])
+		require.Len(t, resp.Data["kept_keys"], 2)
+	})
+}
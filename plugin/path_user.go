@@ -0,0 +1,207 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	userPath        = "users/"
+	pathUserHelpSyn = `
+	Generate a datadog user for the requesting Vault entity from a role.
+	`
+	pathUserHelpDesc = `
+	This path gives the requesting Vault entity a datadog user holding
+	the datadog roles of a particular role. The email of the user is
+	read from the metadata or the aliases of the entity. A new user is invited to log
+	in to datadog; a user created by an earlier request is enabled
+	again. When the lease ends, the user is removed from its roles and
+	disabled.
+	`
+)
+
+func pathUser(b *datadogBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: userPath + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathUserRead,
+			logical.UpdateOperation: b.pathUserRead,
+		},
+		HelpSynopsis:    pathUserHelpSyn,
+		HelpDescription: pathUserHelpDesc,
+	}
+}
+
+func (b *datadogBackend) pathUserRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	if !roleEntry.allowsCredentialType(credentialTypeUser) {
+		return logical.ErrorResponse("role %q is not permitted to issue users", roleName), logical.ErrPermissionDenied
+	}
+
+	if roleEntry.Disabled {
+		return logical.ErrorResponse("role %q is disabled", roleName), logical.ErrPermissionDenied
+	}
+
+	requester, errResp, err := b.getRequester(req, roleEntry)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	if resp, err := b.limitKeyCreation(ctx, req.Storage, roleEntry, 1); resp != nil || err != nil {
+		return resp, err
+	}
+
+	release, limitResp, err := b.limitActiveKeys(ctx, req.Storage, roleEntry, 1)
+	if limitResp != nil || err != nil {
+		return limitResp, err
+	}
+	defer release()
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	// datadog users are unique by email, whether they exist yet or not
+	lock := locksutil.LockForKey(b.issueLocks, strings.ToLower(requester.Email))
+	lock.Lock()
+	defer lock.Unlock()
+
+	user, invited, errResp, err := b.prepareUser(ctx, req.Storage, client, roleEntry, requester)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	issued := b.newIssuedKey(req, roleEntry, credentialTypeUser, user.ID)
+	if err := b.putIssuedKey(ctx, req.Storage, issued); err != nil {
+		if rollbackErr := deleteUser(ctx, client, user.ID); rollbackErr != nil {
+			b.Logger().Error("error rolling back datadog user", "user_id", user.ID, "error", rollbackErr)
+		}
+		return nil, fmt.Errorf("error recording issued user: %w", err)
+	}
+
+	resp := b.Secret(datadogUserType).Response(map[string]interface{}{
+		"user_id":          user.ID,
+		"email":            user.Email,
+		"datadog_role_ids": roleEntry.DatadogRoleIDs,
+		"invited":          invited,
+	}, issued.internalData(map[string]interface{}{
+		"user_id": user.ID,
+		"role":    roleEntry.Name,
+	}))
+
+	if roleEntry.TTL > 0 {
+		resp.Secret.TTL = roleEntry.TTL
+	}
+
+	if roleEntry.MaxTTL > 0 {
+		resp.Secret.MaxTTL = roleEntry.MaxTTL
+	}
+
+	return resp, nil
+}
+
+// prepareUser returns an enabled datadog user with the email of
+// the requester holding the datadog roles of a role, and whether it was invited.
+// The user is created and invited if it doesn't exist. Otherwise it
+// must have been created by the backend and have no outstanding
+// lease, and it is given the roles and enabled again.
+func (b *datadogBackend) prepareUser(ctx context.Context, s logical.Storage, client *datadogClient, roleEntry *datadogRoleEntry, requester *requester) (*datadogUser, bool, *logical.Response, error) {
+
+	email := requester.Email
+
+	user, err := client.getUserByEmail(ctx, email)
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("error looking up datadog user: %w", err)
+	}
+
+	rollback := func(user *datadogUser) {
+		if err := deleteUser(ctx, client, user.ID); err != nil {
+			b.Logger().Error("error rolling back datadog user", "user_id", user.ID, "error", err)
+		}
+	}
+
+	if user == nil {
+		user, err := client.createUser(ctx, email, requester.Name, roleEntry.DatadogRoleIDs)
+		if err != nil {
+			return nil, false, nil, fmt.Errorf("error creating datadog user: %w", err)
+		}
+
+		err = putManagedUser(ctx, s, &managedUser{
+			UserID:     user.ID,
+			Email:      user.Email,
+			CreateTime: time.Now().UTC(),
+		})
+		if err != nil {
+			rollback(user)
+			return nil, false, nil, fmt.Errorf("error recording managed user: %w", err)
+		}
+
+		if err := client.inviteUser(ctx, user.ID); err != nil {
+			rollback(user)
+			return nil, false, nil, fmt.Errorf("error inviting datadog user: %w", err)
+		}
+
+		return user, true, nil, nil
+	}
+
+	// revoking a user disables it, so only
+	// users created by the backend are issued
+	managed, err := getManagedUser(ctx, s, user.ID)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if managed == nil {
+		return nil, false, logical.ErrorResponse("datadog user %q already exists and was not created by this backend", email), nil
+	}
+
+	outstanding, err := getIssuedKey(ctx, s, user.ID)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	// the lease of a user whose revocation failed has ended, but
+	// that of a user of a revoke-all is yet to be revoked
+	if outstanding != nil && outstanding.RevocationError == "" {
+		return nil, false, logical.ErrorResponse("datadog user %q was already issued and its lease has not ended", email), nil
+	}
+
+	// the roles are set before the user is enabled so
+	// that it never holds the roles of an earlier lease
+	if err := setUserRoles(ctx, client, user, roleEntry.DatadogRoleIDs); err != nil {
+		rollback(user)
+		return nil, false, nil, fmt.Errorf("error setting datadog user roles: %w", err)
+	}
+
+	if user.Disabled {
+		if err := client.enableUser(ctx, user.ID); err != nil {
+			rollback(user)
+			return nil, false, nil, fmt.Errorf("error enabling datadog user: %w", err)
+		}
+	}
+
+	return user, false, nil, nil
+}
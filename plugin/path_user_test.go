@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUser(t *testing.T) {
+	b, s := getTestBackend(t)
+	srv := newTestDatadogServer(t)
+	configureTestBackend(t, b, s, srv)
+
+	datadogRoleIDs := []string{"role-admin"}
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"credential_types": []string{credentialTypeUser},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"credential_types": []string{credentialTypeUser},
+		"datadog_role_ids": datadogRoleIDs,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	sys := b.System().(*testSystemView)
+	setEntity := func(email string) {
+		sys.EntityVal = &logical.Entity{
+			ID:       "test-entity",
+			Name:     "alice",
+			Metadata: map[string]string{"email": email},
+		}
+	}
+
+	issue := func(t *testing.T, entityID string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      userPath + roleName,
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+
+	revoke := func(t *testing.T, secret *logical.Secret) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("No Entity", func(t *testing.T) {
+		_, err := issue(t, "")
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+	})
+
+	t.Run("No Email", func(t *testing.T) {
+		setEntity("")
+		resp, err := issue(t, "test-entity")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	setEntity("alice@example.com")
+
+	var userID string
+	t.Run("Issue And Revoke", func(t *testing.T) {
+		resp, err := issue(t, "test-entity")
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, "alice@example.com", resp.Data["email"])
+		require.Equal(t, true, resp.Data["invited"])
+
+		userID = resp.Data["user_id"].(string)
+		require.Contains(t, srv.users, userID)
+		user := srv.users[userID]
+		require.False(t, user.ServiceAccount)
+		require.True(t, user.Invited)
+		require.Equal(t, datadogRoleIDs, user.Roles)
+		require.Equal(t, "alice", user.Name)
+
+		// only one lease per user
+		dupResp, err := issue(t, "test-entity")
+		require.NoError(t, err)
+		require.True(t, dupResp.IsError())
+
+		revoke(t, resp.Secret)
+		require.True(t, user.Disabled)
+		require.Empty(t, user.Roles)
+	})
+
+	t.Run("Reissue", func(t *testing.T) {
+		srv.users[userID].Invited = false
+
+		resp, err := issue(t, "test-entity")
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, userID, resp.Data["user_id"])
+		require.Equal(t, false, resp.Data["invited"])
+
+		user := srv.users[userID]
+		require.False(t, user.Disabled)
+		require.False(t, user.Invited)
+		require.Equal(t, datadogRoleIDs, user.Roles)
+
+		revoke(t, resp.Secret)
+		require.True(t, user.Disabled)
+	})
+
+	t.Run("Concurrent Reissue", func(t *testing.T) {
+		var wg sync.WaitGroup
+		resps := make(chan *logical.Response, 2)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := issue(t, "test-entity")
+				require.NoError(t, err)
+				resps <- resp
+			}()
+		}
+		wg.Wait()
+		close(resps)
+
+		// only one of the requests enables the user
+		var issued *logical.Response
+		for resp := range resps {
+			if !resp.IsError() {
+				require.Nil(t, issued)
+				issued = resp
+			}
+		}
+		require.NotNil(t, issued)
+
+		revoke(t, issued.Secret)
+		require.True(t, srv.users[userID].Disabled)
+	})
+
+	t.Run("Existing User", func(t *testing.T) {
+		srv.users["existing"] = &testDatadogUser{Email: "bob@example.com", Name: "bob"}
+		setEntity("bob@example.com")
+
+		resp, err := issue(t, "test-entity")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.False(t, srv.users["existing"].Disabled)
+	})
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultRateLimitMaxWait = 10 * time.Second
+)
+
+// requestLimiter is a token bucket allowing perMinute requests
+// per minute, in bursts of up to perMinute requests
+type requestLimiter struct {
+	*rate.Limiter
+	perMinute int
+}
+
+func newRequestLimiter(perMinute int) *requestLimiter {
+	return &requestLimiter{
+		Limiter:   rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute),
+		perMinute: perMinute,
+	}
+}
+
+// keyCreationLimiter limits the rate at which keys are created in
+// datadog, across the backend and for each role
+type keyCreationLimiter struct {
+	lock   sync.Mutex
+	global *requestLimiter
+	roles  map[string]*requestLimiter
+}
+
+func newKeyCreationLimiter() *keyCreationLimiter {
+	return &keyCreationLimiter{
+		roles: make(map[string]*requestLimiter),
+	}
+}
+
+// limiters returns the limiters that apply to a role, replacing
+// those whose limit has changed since they were created
+func (l *keyCreationLimiter) limiters(config *datadogConfig, roleEntry *datadogRoleEntry) []*requestLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var limiters []*requestLimiter
+
+	if config.MaxRequestsPerMinute <= 0 {
+		l.global = nil
+	} else {
+		if l.global == nil || l.global.perMinute != config.MaxRequestsPerMinute {
+			l.global = newRequestLimiter(config.MaxRequestsPerMinute)
+		}
+		limiters = append(limiters, l.global)
+	}
+
+	if roleEntry.MaxRequestsPerMinute <= 0 {
+		delete(l.roles, roleEntry.Name)
+	} else {
+		if r, ok := l.roles[roleEntry.Name]; !ok || r.perMinute != roleEntry.MaxRequestsPerMinute {
+			l.roles[roleEntry.Name] = newRequestLimiter(roleEntry.MaxRequestsPerMinute)
+		}
+		limiters = append(limiters, l.roles[roleEntry.Name])
+	}
+
+	return limiters
+}
+
+// forgetRole drops the limiter of a deleted role
+func (l *keyCreationLimiter) forgetRole(name string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.roles, name)
+}
+
+// status describes the limiters that have been used
+func (l *keyCreationLimiter) status() map[string]interface{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	describe := func(r *requestLimiter) map[string]interface{} {
+		return map[string]interface{}{
+			"max_requests_per_minute": r.perMinute,
+			"available":               int(math.Floor(r.TokensAt(now))),
+		}
+	}
+
+	roles := make(map[string]interface{}, len(l.roles))
+	for name, r := range l.roles {
+		roles[name] = describe(r)
+	}
+
+	var global map[string]interface{}
+	if l.global != nil {
+		global = describe(l.global)
+	}
+
+	return map[string]interface{}{
+		"global": global,
+		"roles":  roles,
+	}
+}
+
+// limitKeyCreation waits until keys can be created for a role without
+// exceeding the configured rate limits. If that would take longer than
+// the configured maximum wait, it returns an error response carrying
+// logical.ErrRateLimitQuotaExceeded instead.
+func (b *datadogBackend) limitKeyCreation(ctx context.Context, s logical.Storage, roleEntry *datadogRoleEntry, keys int) (*logical.Response, error) {
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("error getting config: %w", err)
+	}
+
+	if config == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var delay time.Duration
+	var reservations []*rate.Reservation
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	for _, l := range b.limiter.limiters(config, roleEntry) {
+		r := l.ReserveN(now, keys)
+		if !r.OK() {
+			cancel()
+			return logical.ErrorResponse("creating %d keys exceeds the limit of %d requests per minute", keys, l.perMinute), logical.ErrRateLimitQuotaExceeded
+		}
+		reservations = append(reservations, r)
+		delay = max(delay, r.DelayFrom(now))
+	}
+
+	if delay == 0 {
+		return nil, nil
+	}
+
+	if delay > config.getRateLimitMaxWait() {
+		cancel()
+		return logical.ErrorResponse("too many requests to create datadog keys, retry in %s", delay.Round(time.Second)), logical.ErrRateLimitQuotaExceeded
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, nil
+	}
+}
@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	walRootRotationKind       = "rootRotation"
+	walStaticRoleRotationKind = "staticRoleRotation"
+	walRollbackMinAge         = 10 * time.Minute
+)
+
+// walRootRotation records the progress of a root credential
+// rotation so that it can be rolled back or completed if the
+// rotation is interrupted. Old key IDs are only set for the
+// keys being rotated.
+type walRootRotation struct {
+	OldAPIKeyID string `json:"old_api_key_id"`
+	OldAppKeyID string `json:"old_app_key_id"`
+	NewAPIKeyID string `json:"new_api_key_id"`
+	NewAppKeyID string `json:"new_app_key_id"`
+	KeepOld     bool   `json:"keep_old"`
+
+	// DeleteAfter is set when the old keys are kept for a
+	// grace period rather than deleted immediately
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// walStaticRoleRotation records the progress of a static
+// role rotation
+type walStaticRoleRotation struct {
+	Role     string `json:"role"`
+	KeyType  string `json:"key_type"`
+	OldKeyID string `json:"old_key_id"`
+	NewKeyID string `json:"new_key_id"`
+}
+
+// walRollback is invoked by Vault for WAL entries left behind by
+// operations that did not finish
+func (b *datadogBackend) walRollback(ctx context.Context, req *logical.Request, kind string, data interface{}) error {
+
+	switch kind {
+	case walRootRotationKind:
+		var entry walRootRotation
+		if err := decodeWAL(data, &entry); err != nil {
+			return err
+		}
+		return b.rollbackRootRotation(ctx, req.Storage, &entry)
+	case walStaticRoleRotationKind:
+		var entry walStaticRoleRotation
+		if err := decodeWAL(data, &entry); err != nil {
+			return err
+		}
+		return b.rollbackStaticRoleRotation(ctx, req.Storage, &entry)
+	default:
+		return fmt.Errorf("unknown WAL entry kind %q", kind)
+	}
+}
+
+// rollbackRootRotation completes an interrupted root rotation if the new
+// credentials were stored, or otherwise deletes the keys it created
+func (b *datadogBackend) rollbackRootRotation(ctx context.Context, s logical.Storage, entry *walRootRotation) error {
+
+	b.rootRotationLock.Lock()
+	defer b.rootRotationLock.Unlock()
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting config: %w", err)
+	}
+
+	if config == nil {
+		b.Logger().Warn("discarding root rotation WAL entry, configuration not set")
+		return nil
+	}
+
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	// the rotation was committed if every key being rotated was
+	// replaced in the stored config
+	committed := (entry.NewAPIKeyID != "" || entry.NewAppKeyID != "") &&
+		(entry.OldAPIKeyID == "" || config.APIKeyID == entry.NewAPIKeyID) &&
+		(entry.OldAppKeyID == "" || config.AppKeyID == entry.NewAppKeyID)
+
+	var apiKeyID, appKeyID string
+	if !committed {
+		apiKeyID, appKeyID = entry.NewAPIKeyID, entry.NewAppKeyID
+	} else if !entry.KeepOld && !entry.DeleteAfter.IsZero() {
+		if err := queueRootKeyDeletions(ctx, s, entry, entry.DeleteAfter); err != nil {
+			return fmt.Errorf("error queueing old keys for deletion: %w", err)
+		}
+		b.Logger().Info("completed interrupted root rotation, old keys queued for deletion", "delete_after", entry.DeleteAfter)
+		return nil
+	} else if entry.KeepOld {
+		if err := queueRootKeyDeletions(ctx, s, entry, time.Time{}); err != nil {
+			return fmt.Errorf("error recording kept keys: %w", err)
+		}
+	} else {
+		apiKeyID, appKeyID = entry.OldAPIKeyID, entry.OldAppKeyID
+	}
+
+	if apiKeyID != "" && apiKeyID != config.APIKeyID {
+		if err := deleteAPIKey(ctx, client, apiKeyID); err != nil && statusCode(err) != http.StatusNotFound {
+			return err
+		}
+	}
+	if appKeyID != "" && appKeyID != config.AppKeyID {
+		if err := deleteAppKey(ctx, client, appKeyID); err != nil && statusCode(err) != http.StatusNotFound {
+			return err
+		}
+	}
+
+	if committed {
+		b.Logger().Info("completed interrupted root rotation", "api_key_id", apiKeyID, "app_key_id", appKeyID)
+	} else {
+		b.Logger().Info("rolled back interrupted root rotation", "api_key_id", apiKeyID, "app_key_id", appKeyID)
+	}
+
+	return nil
+}
+
+// rollbackStaticRoleRotation deletes the old key of an interrupted static
+// role rotation if the new key was stored, or otherwise the new key
+func (b *datadogBackend) rollbackStaticRoleRotation(ctx context.Context, s logical.Storage, entry *walStaticRoleRotation) error {
+
+	if entry.NewKeyID == "" {
+		return nil
+	}
+
+	roleEntry, err := getStaticRole(ctx, s, entry.Role)
+	if err != nil {
+		return fmt.Errorf("error retrieving static role: %w", err)
+	}
+
+	keyID := entry.NewKeyID
+	if roleEntry != nil && roleEntry.KeyID == entry.NewKeyID {
+		keyID = entry.OldKeyID
+	}
+
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	if err := deleteKey(ctx, client, entry.KeyType, keyID); err != nil && statusCode(err) != http.StatusNotFound {
+		return err
+	}
+
+	b.Logger().Info("cleaned up interrupted static role rotation", "role", entry.Role, "key_id", keyID)
+
+	return nil
+}
+
+// replaceWAL stores data as a new WAL entry and deletes the entry with
+// the given ID, returning the ID of the new entry
+func replaceWAL(ctx context.Context, s logical.Storage, id string, kind string, data interface{}) (string, error) {
+
+	newID, err := framework.PutWAL(ctx, s, kind, data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := framework.DeleteWAL(ctx, s, id); err != nil {
+		return "", err
+	}
+
+	return newID, nil
+}
+
+// decodeWAL decodes the data of a WAL entry into out
+func decodeWAL(data interface{}, out interface{}) error {
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error decoding WAL entry: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("error decoding WAL entry: %w", err)
+	}
+
+	return nil
+}